@@ -0,0 +1,257 @@
+package httpfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessLogEntry holds the information of a request served by the `FileServer`.
+// See `Options.AccessLog`.
+type AccessLogEntry struct {
+	// Time is the time the request was received.
+	Time time.Time
+	// Request is the served request.
+	Request *http.Request
+	// Name is the resolved file name, e.g. "/index.html" on a "/" request.
+	Name string
+	// Status is the response status code.
+	Status int
+	// Bytes is the number of body bytes actually written
+	// to the client, after compression.
+	Bytes int64
+	// Encoding is the negotiated Content-Encoding, if any.
+	Encoding string
+	// Cached reports whether the file was served by a `Cache` file system.
+	Cached bool
+	// Index reports whether an `IndexName(s)` file of a directory was served.
+	Index bool
+	// SPA reports whether the `SPA` fallback to the index file was applied.
+	SPA bool
+	// Pushed is the number of the (HTTP/2) pushed assets.
+	Pushed int
+	// Reason is the reason of a denied `Options.Authorize` decision, if any.
+	Reason string
+	// Duration is the time it took to serve the request.
+	Duration time.Duration
+}
+
+// AccessLogFunc is the function signature of the `Options.AccessLog` field.
+// See `CommonLog`, `CombinedLog` and `JSONLog` too.
+type AccessLogFunc func(entry *AccessLogEntry)
+
+// CommonLog returns an `AccessLogFunc` which writes
+// each entry to "w" in the Common Log Format, e.g.
+// 127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326
+func CommonLog(w io.Writer) AccessLogFunc {
+	return textLog(w, false)
+}
+
+// CombinedLog returns an `AccessLogFunc` which writes
+// each entry to "w" in the Combined Log Format,
+// which is the Common Log Format followed by the referer and user agent, e.g.
+// 127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 200 2326 "http://example.com/" "Mozilla/5.0"
+func CombinedLog(w io.Writer) AccessLogFunc {
+	return textLog(w, true)
+}
+
+func textLog(w io.Writer, combined bool) AccessLogFunc {
+	mu := new(sync.Mutex)
+
+	return func(entry *AccessLogEntry) {
+		r := entry.Request
+
+		bytes := "-"
+		if entry.Bytes > 0 {
+			bytes = fmt.Sprintf("%d", entry.Bytes)
+		}
+
+		line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+			remoteHost(r),
+			orDash(userName(r)),
+			entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+			r.Method, escapeLogValue(r.RequestURI), r.Proto,
+			entry.Status,
+			bytes,
+		)
+
+		if combined {
+			line += fmt.Sprintf(" \"%s\" \"%s\"", orDash(escapeLogValue(r.Referer())), orDash(escapeLogValue(r.UserAgent())))
+		}
+
+		mu.Lock()
+		io.WriteString(w, line+"\n")
+		mu.Unlock()
+	}
+}
+
+// JSONLog returns an `AccessLogFunc` which writes
+// each entry to "w" as a JSON object followed by a new line.
+func JSONLog(w io.Writer) AccessLogFunc {
+	mu := new(sync.Mutex)
+	enc := json.NewEncoder(w)
+
+	return func(entry *AccessLogEntry) {
+		r := entry.Request
+
+		v := struct {
+			Time       time.Time `json:"time"`
+			RemoteAddr string    `json:"remote_addr"`
+			Method     string    `json:"method"`
+			URI        string    `json:"uri"`
+			Proto      string    `json:"proto"`
+			Name       string    `json:"name"`
+			Status     int       `json:"status"`
+			Bytes      int64     `json:"bytes"`
+			Encoding   string    `json:"encoding,omitempty"`
+			Cached     bool      `json:"cached"`
+			Index      bool      `json:"index"`
+			SPA        bool      `json:"spa"`
+			Pushed     int       `json:"pushed"`
+			Reason     string    `json:"reason,omitempty"`
+			Duration   float64   `json:"duration_ms"`
+			Referer    string    `json:"referer,omitempty"`
+			UserAgent  string    `json:"user_agent,omitempty"`
+		}{
+			Time:       entry.Time,
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			URI:        r.RequestURI,
+			Proto:      r.Proto,
+			Name:       entry.Name,
+			Status:     entry.Status,
+			Bytes:      entry.Bytes,
+			Encoding:   entry.Encoding,
+			Cached:     entry.Cached,
+			Index:      entry.Index,
+			SPA:        entry.SPA,
+			Pushed:     entry.Pushed,
+			Reason:     entry.Reason,
+			Duration:   float64(entry.Duration) / float64(time.Millisecond),
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+		}
+
+		mu.Lock()
+		enc.Encode(v)
+		mu.Unlock()
+	}
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return orDash(r.RemoteAddr)
+	}
+
+	return host
+}
+
+func userName(r *http.Request) string {
+	if r.URL != nil && r.URL.User != nil {
+		return r.URL.User.Username()
+	}
+
+	if username, _, ok := r.BasicAuth(); ok {
+		return escapeLogValue(username)
+	}
+
+	return ""
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+// escapeLogValue escapes the quotes and control characters
+// of a client-controlled value to keep a log line intact.
+func escapeLogValue(s string) string {
+	if !strings.ContainsAny(s, "\"\\\r\n\t") {
+		return s
+	}
+
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	s = strings.ReplaceAll(s, "\r", "\\r")
+	s = strings.ReplaceAll(s, "\n", "\\n")
+	return strings.ReplaceAll(s, "\t", "\\t")
+}
+
+// accessLogWriter records the status code and the
+// written bytes of a response for the `Options.AccessLog`.
+type accessLogWriter struct {
+	http.ResponseWriter
+	entry       *AccessLogEntry
+	wroteHeader bool
+}
+
+var (
+	_ http.Flusher = (*accessLogWriter)(nil)
+	_ http.Pusher  = (*accessLogPusher)(nil)
+)
+
+// newAccessLogWriter returns an `accessLogWriter` of the "w",
+// which implements the http.Pusher only if the "w" does.
+func newAccessLogWriter(w http.ResponseWriter, entry *AccessLogEntry) http.ResponseWriter {
+	lw := &accessLogWriter{ResponseWriter: w, entry: entry}
+	if _, ok := w.(http.Pusher); ok {
+		return &accessLogPusher{lw}
+	}
+
+	return lw
+}
+
+func (w *accessLogWriter) WriteHeader(statusCode int) {
+	// informational responses, e.g. 103 Early Hints, are followed by the final one.
+	if !w.wroteHeader && statusCode >= 200 {
+		w.wroteHeader = true
+		w.entry.Status = statusCode
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *accessLogWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.entry.Bytes += int64(n)
+	return n, err
+}
+
+func (w *accessLogWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the original response writer, see `http.ResponseController`.
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// accessLogPusher is the `accessLogWriter` of a response writer
+// which supports HTTP/2 Push, it counts the pushed assets.
+type accessLogPusher struct {
+	*accessLogWriter
+}
+
+func (w *accessLogPusher) Push(target string, opts *http.PushOptions) error {
+	err := w.ResponseWriter.(http.Pusher).Push(target, opts)
+	if err == nil {
+		w.entry.Pushed++
+	}
+
+	return err
+}
@@ -0,0 +1,71 @@
+package httpfs
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	root := testDir(t, map[string]string{"index.html": "index", "main.js": "js"})
+
+	var entries []*AccessLogEntry
+	opts := DefaultOptions
+	opts.Compress = false
+	opts.PushTargets = map[string][]string{"/": {"/main.js"}}
+	opts.PushPolicy = PushOncePerVersion
+	opts.AccessLog = func(entry *AccessLogEntry) { entries = append(entries, entry) }
+	h := FileServer(http.Dir(root), opts)
+
+	// HTTP/1.1 clients are not pushed, nor they are given a push digest cookie.
+	rec := serveTest(h, http.MethodGet, "/")
+	if cookie := rec.Header().Get("Set-Cookie"); cookie != "" {
+		t.Fatalf("expected no push cookie but got %q", cookie)
+	}
+
+	pw := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(pw, httptest.NewRequest(http.MethodGet, "/", nil))
+	if len(pw.pushed) != 1 || pw.Header().Get("Set-Cookie") == "" {
+		t.Fatalf("expected one push and a push cookie but got %v, %q", pw.pushed, pw.Header().Get("Set-Cookie"))
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries but got %d", len(entries))
+	}
+
+	if entry := entries[0]; entry.Status != http.StatusOK || entry.Name != "/index.html" || !entry.Index || entry.Pushed != 0 || entry.Bytes != 5 {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+
+	if entry := entries[1]; entry.Pushed != 1 {
+		t.Fatalf("expected 1 pushed asset but got %d", entry.Pushed)
+	}
+}
+
+func TestCommonLog(t *testing.T) {
+	root := testDir(t, map[string]string{"a.txt": "abc"})
+
+	var buf bytes.Buffer
+	opts := DefaultOptions
+	opts.Compress = false
+	opts.AccessLog = CombinedLog(&buf)
+	h := FileServer(http.Dir(root), opts)
+
+	serveTest(h, http.MethodGet, "/a.txt", "User-Agent", "agent \"x\"")
+	serveTest(h, http.MethodGet, "/missing")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines but got %q", buf.String())
+	}
+
+	if !strings.Contains(lines[0], `"GET /a.txt HTTP/1.1" 200 3 "-" "agent \"x\""`) {
+		t.Errorf("unexpected line: %s", lines[0])
+	}
+
+	if !strings.Contains(lines[1], `"GET /missing HTTP/1.1" 404`) {
+		t.Errorf("unexpected line: %s", lines[1])
+	}
+}
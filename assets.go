@@ -0,0 +1,171 @@
+package httpfs
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kataras/compress"
+	"golang.org/x/net/html"
+)
+
+// indexAssets holds the asset references of an index HTML file,
+// parsed once per file contents, see `Options.PushAssets`.
+type indexAssets struct {
+	once sync.Once
+	refs []string
+
+	// the contents version of a non cached file.
+	modTime time.Time
+	size    int64
+}
+
+// assetTargets returns the assets of the served index HTML file "f",
+// resolved against the request URL like a browser does.
+// The parsed references are kept in the `Cache` file itself
+// or per file name and modification time for the rest of the file systems.
+func (s *fileServer) assetTargets(r *http.Request, name string, f http.File, info os.FileInfo) []string {
+	if ext := strings.ToLower(path.Ext(name)); ext != ".html" && ext != ".htm" {
+		return nil
+	}
+
+	var assets *indexAssets
+	if cached, ok := f.(*file); ok && cached.assets != nil {
+		assets = cached.assets
+	} else {
+		v, ok := s.assets.Load(name)
+		if ok {
+			assets = v.(*indexAssets)
+		}
+
+		if !ok || !assets.modTime.Equal(info.ModTime()) || assets.size != info.Size() {
+			assets = &indexAssets{modTime: info.ModTime(), size: info.Size()}
+			s.assets.Store(name, assets)
+		}
+	}
+
+	assets.once.Do(func() {
+		refs, err := parseAssets(f)
+		if err != nil {
+			s.reportError(r, name, StagePush, err)
+		}
+		assets.refs = refs
+	})
+
+	if len(assets.refs) == 0 {
+		return nil
+	}
+
+	// relative references are resolved against the directory of the request URL.
+	base := r.URL.Path
+	if uri, _, _ := strings.Cut(r.RequestURI, "?"); uri != "" {
+		base = uri
+	}
+	if !strings.HasSuffix(base, "/") {
+		base = path.Dir(base)
+	}
+
+	targets := make([]string, 0, len(assets.refs))
+	for _, ref := range assets.refs {
+		if ref[0] == '/' {
+			targets = append(targets, ref)
+			continue
+		}
+
+		refPath, query, hasQuery := strings.Cut(ref, "?")
+		target := path.Join(base, refPath)
+		if hasQuery {
+			target += "?" + query
+		}
+
+		targets = append(targets, target)
+	}
+
+	return targets
+}
+
+// parseAssets reads the "f" HTML file and returns the same-origin references of its
+// "script src", "link rel=stylesheet/icon/modulepreload/preload href" and "img src" elements.
+// Lazy loaded images are omitted. The file is rewound afterwards.
+func parseAssets(f http.File) ([]string, error) {
+	defer f.Seek(0, io.SeekStart)
+
+	var rd io.Reader = f
+	if encoding, _ := GetEncoding(f); encoding != "" {
+		cr, err := compress.NewReader(f, encoding)
+		if err != nil {
+			return nil, err
+		}
+		defer cr.Close()
+		rd = cr
+	}
+
+	var (
+		refs []string
+		seen = make(map[string]struct{})
+	)
+
+	add := func(ref string) {
+		ref = strings.TrimSpace(ref)
+		if idx := strings.IndexByte(ref, '#'); idx != -1 {
+			ref = ref[:idx]
+		}
+
+		// skip other origins, e.g. "https://cdn.example.com/x.js" and "//cdn.example.com/x.js",
+		// and inline data, e.g. "data:image/png;base64,...".
+		if ref == "" || strings.HasPrefix(ref, "//") || strings.Contains(strings.SplitN(ref, "/", 2)[0], ":") {
+			return
+		}
+
+		if _, ok := seen[ref]; ok {
+			return
+		}
+
+		seen[ref] = struct{}{}
+		refs = append(refs, ref)
+	}
+
+	z := html.NewTokenizer(rd)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return refs, err
+			}
+
+			return refs, nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tag, hasAttr := z.TagName()
+			if !hasAttr {
+				continue
+			}
+
+			attrs := make(map[string]string)
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = z.TagAttr()
+				attrs[string(key)] = string(value)
+			}
+
+			switch string(tag) {
+			case "script":
+				add(attrs["src"])
+			case "img":
+				if !strings.EqualFold(attrs["loading"], "lazy") {
+					add(attrs["src"])
+				}
+			case "link":
+				for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+					if rel == "stylesheet" || rel == "icon" || rel == "modulepreload" || rel == "preload" {
+						add(attrs["href"])
+						break
+					}
+				}
+			}
+		}
+	}
+}
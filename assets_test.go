@@ -0,0 +1,165 @@
+package httpfs
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const assetsPage = `<!DOCTYPE html>
+<html>
+<head>
+	<link rel="stylesheet" href="css/a.css?v=1">
+	<link rel="ICON preload" href="/favicon.ico">
+	<link rel="preconnect" href="/preconnect">
+	<link rel="stylesheet" href="https://cdn.example.com/b.css">
+	<script src="//cdn.example.com/c.js"></script>
+	<script src="../shared/app.js"></script>
+	<script src="../shared/app.js"></script>
+	<script>inline()</script>
+</head>
+<body>
+	<img src="/logo.png#top">
+	<img src="data:image/png;base64,iVBORw0KGgo=">
+	<img src="lazy.png" loading="lazy">
+	<img src="mailto:a@example.com">
+</body>
+</html>`
+
+var assetsRefs = []string{"css/a.css?v=1", "/favicon.ico", "../shared/app.js", "/logo.png"}
+
+func TestParseAssets(t *testing.T) {
+	root := testDir(t, map[string]string{"index.html": assetsPage})
+
+	f, err := os.Open(filepath.Join(root, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	refs, err := parseAssets(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(refs, assetsRefs) {
+		t.Errorf("expected the references %v but got %v", assetsRefs, refs)
+	}
+
+	// the file is rewound to be served.
+	if offset, _ := f.Seek(0, io.SeekCurrent); offset != 0 {
+		t.Errorf("expected the file to be rewound but it is at %d", offset)
+	}
+}
+
+func TestAssetTargets(t *testing.T) {
+	root := testDir(t, map[string]string{"docs/index.html": assetsPage})
+
+	opts := DefaultOptions
+	opts.PushAssets = true
+	opts.Preload = true
+	h := FileServer(http.Dir(root), opts)
+
+	// relative references are resolved against the request URL.
+	tests := []struct {
+		handler http.Handler
+		target  string
+		links   []string
+	}{
+		{h, "/docs/", []string{
+			"</docs/css/a.css?v=1>; rel=preload; as=style",
+			"</favicon.ico>; rel=preload; as=image",
+			"</shared/app.js>; rel=preload; as=script",
+			"</logo.png>; rel=preload; as=image",
+		}},
+		{h, "/docs/?v=1", []string{
+			"</docs/css/a.css?v=1>; rel=preload; as=style",
+			"</favicon.ico>; rel=preload; as=image",
+			"</shared/app.js>; rel=preload; as=script",
+			"</logo.png>; rel=preload; as=image",
+		}},
+		{http.StripPrefix("/public", h), "/public/docs/", []string{
+			"</public/docs/css/a.css?v=1>; rel=preload; as=style",
+			"</favicon.ico>; rel=preload; as=image",
+			"</public/shared/app.js>; rel=preload; as=script",
+			"</logo.png>; rel=preload; as=image",
+		}},
+	}
+
+	for _, tt := range tests {
+		rec := serveTest(tt.handler, http.MethodGet, tt.target)
+		if links := rec.Header().Values("Link"); !reflect.DeepEqual(links, tt.links) {
+			t.Errorf("%s: expected links %v but got %v", tt.target, tt.links, links)
+		}
+	}
+}
+
+func TestAssetTargetsCache(t *testing.T) {
+	root := testDir(t, map[string]string{"index.html": assetsPage})
+
+	opts := DefaultOptions
+	opts.PushAssets = true
+	opts.Preload = true
+	h := FileServer(MustCache(http.Dir(root), CacheOptions{CompressMinSize: 1, Encodings: []string{"gzip"}}), opts)
+
+	expected := []string{
+		"</css/a.css?v=1>; rel=preload; as=style",
+		"</favicon.ico>; rel=preload; as=image",
+		"</shared/app.js>; rel=preload; as=script",
+		"</logo.png>; rel=preload; as=image",
+	}
+
+	// the compressed cached file is parsed too.
+	for _, encoding := range []string{"gzip", ""} {
+		rec := serveTest(h, http.MethodGet, "/", "Accept-Encoding", encoding)
+		if got := rec.Header().Get("Content-Encoding"); rec.Code != http.StatusOK || got != encoding {
+			t.Fatalf("%q: expected the cached index file but got %d (%q)", encoding, rec.Code, got)
+		}
+
+		if links := rec.Header().Values("Link"); !reflect.DeepEqual(links, expected) {
+			t.Errorf("%q: expected links %v but got %v", encoding, expected, links)
+		}
+	}
+}
+
+func TestAssetTargetsReparse(t *testing.T) {
+	root := testDir(t, map[string]string{"index.html": `<script src="/a.js"></script>`})
+	index := filepath.Join(root, "index.html")
+
+	opts := DefaultOptions
+	opts.PushAssets = true
+	opts.Preload = true
+	h := FileServer(http.Dir(root), opts)
+
+	modTime := time.Now().Add(-time.Hour)
+	tests := []struct {
+		contents string
+		modTime  time.Time
+		link     string
+	}{
+		{`<script src="/a.js"></script>`, modTime, "</a.js>; rel=preload; as=script"},
+		// same size, different modification time.
+		{`<script src="/b.js"></script>`, modTime.Add(time.Second), "</b.js>; rel=preload; as=script"},
+		// same modification time, different size.
+		{`<script src="/cc.js"></script>`, modTime.Add(time.Second), "</cc.js>; rel=preload; as=script"},
+	}
+
+	for _, tt := range tests {
+		if err := os.WriteFile(index, []byte(tt.contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Chtimes(index, tt.modTime, tt.modTime); err != nil {
+			t.Fatal(err)
+		}
+
+		rec := serveTest(h, http.MethodGet, "/")
+		if got := rec.Header().Get("Link"); got != tt.link {
+			t.Errorf("%s: expected link %q but got %q", tt.contents, tt.link, got)
+		}
+	}
+}
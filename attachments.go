@@ -0,0 +1,88 @@
+package httpfs
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// AttachmentRule decides whether a set of files is downloaded
+// (saved locally by the client) or served inline. See `Attachments.Rules`.
+type AttachmentRule struct {
+	// Patterns holds the glob patterns (see `path.Match`) of the files
+	// this rule applies to, matched like the `Options.Deny` ones,
+	// e.g. "*.zip" or "/downloads/*". Empty matches all files.
+	Patterns []string
+	// MIMETypes holds the media types of the files this rule applies to,
+	// e.g. "application/zip" or "image/*". The media type is resolved by the file extension.
+	// Empty matches all files.
+	MIMETypes []string
+	// Query, if not empty, is a query parameter the requests should have
+	// for this rule to apply, e.g. "download" for "?download=1" links.
+	// The "0" and "false" values do not match. Query rules do not apply to the `DirList` entries.
+	Query string
+	// Inline, if true, serves the matched files inline instead of downloading them,
+	// e.g. "*.pdf" files when the `Attachments.Enable` is true.
+	Inline bool
+	// Limit and Burst are the bytes per second of the matched downloads,
+	// each one defaults to the `Attachments` one. A zero Burst allows one second of the Limit.
+	Limit float64
+	Burst int
+	// NameFunc changes the sent filename of the matched downloads,
+	// defaults to the `Attachments.NameFunc`.
+	NameFunc func(r *http.Request, systemName string) (attachmentName string)
+}
+
+func (rule *AttachmentRule) match(r *http.Request, name string) bool {
+	if rule.Query != "" {
+		// the `DirList` entries are not matched by query.
+		if r == nil || !r.URL.Query().Has(rule.Query) {
+			return false
+		}
+
+		if v := strings.ToLower(r.URL.Query().Get(rule.Query)); v == "0" || v == "false" {
+			return false
+		}
+	}
+
+	if !matchAny(rule.Patterns, name) {
+		return false
+	}
+
+	if len(rule.MIMETypes) > 0 {
+		return matchMIME(rule.MIMETypes, mime.TypeByExtension(path.Ext(name)))
+	}
+
+	return true
+}
+
+// attachment returns the settings of the "name" file,
+// the first matching `Rules` entry or the `Attachments` ones,
+// and reports whether it should be downloaded. The "r" can be nil.
+func (a *Attachments) attachment(r *http.Request, name string) (AttachmentRule, bool) {
+	settings := AttachmentRule{Limit: a.Limit, Burst: a.Burst, NameFunc: a.NameFunc}
+
+	for i := range a.Rules {
+		rule := &a.Rules[i]
+		if !rule.match(r, name) {
+			continue
+		}
+
+		if rule.Limit > 0 {
+			settings.Limit = rule.Limit
+		}
+
+		if rule.Burst > 0 {
+			settings.Burst = rule.Burst
+		}
+
+		if rule.NameFunc != nil {
+			settings.NameFunc = rule.NameFunc
+		}
+
+		return settings, !rule.Inline
+	}
+
+	return settings, a.Enable
+}
@@ -0,0 +1,68 @@
+package httpfs
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAttachmentRules(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"a.zip": "zip",
+		"b.pdf": "pdf",
+		"c.png": "png",
+		"d.txt": "txt",
+	})
+
+	opts := DefaultOptions
+	opts.Attachments = Attachments{
+		Enable: true,
+		Rules: []AttachmentRule{
+			{Query: "download"},
+			{MIMETypes: []string{"application/pdf", "image/*"}, Inline: true},
+			{Patterns: []string{"*.zip"}, NameFunc: func(r *http.Request, name string) string {
+				return "pkg-" + r.URL.Query().Get("v") + "-" + name
+			}},
+		},
+	}
+	h := FileServer(http.Dir(root), opts)
+
+	tests := []struct {
+		target      string
+		disposition string
+	}{
+		{"/a.zip?v=1", `attachment; filename="pkg-1-a.zip"; filename*=UTF-8''pkg-1-a.zip`},
+		{"/b.pdf", ""},
+		{"/b.pdf?download=1", `attachment; filename="b.pdf"; filename*=UTF-8''b.pdf`},
+		{"/b.pdf?download=0", ""},
+		{"/c.png", ""},
+		{"/d.txt", `attachment; filename="d.txt"; filename*=UTF-8''d.txt`}, // Enable.
+	}
+
+	for _, tt := range tests {
+		rec := serveTest(h, http.MethodGet, tt.target)
+		if got := rec.Header().Get("Content-Disposition"); got != tt.disposition {
+			t.Errorf("%s: expected Content-Disposition %q but got %q", tt.target, tt.disposition, got)
+		}
+	}
+}
+
+func TestAttachmentRulesLimit(t *testing.T) {
+	root := testDir(t, map[string]string{"a.iso": strings.Repeat("x", 300000)})
+
+	for _, attachments := range []Attachments{
+		{Rules: []AttachmentRule{{Patterns: []string{"*.iso"}, Limit: 1e7}}},
+		{Limit: 1e7, Burst: 64 * KB, Rules: []AttachmentRule{{Patterns: []string{"*.iso"}, Limit: 2e7}}},
+		{Enable: true, Limit: 1e7},
+	} {
+		opts := DefaultOptions
+		opts.Compress = false
+		opts.Attachments = attachments
+		h := FileServer(http.Dir(root), opts)
+
+		rec := serveTest(h, http.MethodGet, "/a.iso")
+		if rec.Code != http.StatusOK || rec.Body.Len() != 300000 {
+			t.Errorf("%+v: expected the whole file but got %d with %d bytes", attachments, rec.Code, rec.Body.Len())
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package httpfs
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Authenticator authenticates the clients of a `FileServer`. See `Options.Auth`.
+type Authenticator interface {
+	// Authenticate returns the principal (e.g. the user name) of the request's credentials
+	// and reports whether they were given and they are valid.
+	Authenticate(r *http.Request) (principal string, ok bool)
+	// Challenge returns the "WWW-Authenticate" header value
+	// sent to the unauthenticated clients, e.g. `Basic realm="files"`.
+	Challenge() string
+}
+
+// Auth holds the authentication and authorization settings of a `FileServer`.
+type Auth struct {
+	// Authenticators are tried in order, the first one
+	// which accepts the request's credentials authenticates the client.
+	// See `BasicAuth`, `Htpasswd` and `BearerTokens`.
+	Authenticators []Authenticator
+	// Rules map file patterns to the principals allowed to access them.
+	// The first rule that matches a file is applied
+	// and files without a matching rule are public.
+	Rules []AuthRule
+}
+
+// AuthRule maps a set of files to the principals allowed to access them.
+type AuthRule struct {
+	// Patterns holds the glob patterns (see `path.Match`) of the files
+	// this rule applies to, matched like the `Options.Deny` ones,
+	// e.g. "/private/*". Empty matches all files.
+	Patterns []string
+	// Principals holds the principals (see `Authenticator`) allowed
+	// to access the files, "*" allows any authenticated client.
+	// Empty makes the files public, e.g. to exclude "/login/*" from a later rule.
+	Principals []string
+}
+
+// authorizer authorizes the files served on a request,
+// the client is authenticated once, on the first file that requires it.
+type authorizer struct {
+	s *fileServer
+	r *http.Request
+
+	authenticated bool
+	principal     string
+	done          bool
+}
+
+// status returns zero if the client is allowed to access the "name" file,
+// 401 Unauthorized if it should authenticate or 403 Forbidden otherwise.
+func (a *authorizer) status(name string) int {
+	var rule *AuthRule
+	for i := range a.s.options.Auth.Rules {
+		if r := &a.s.options.Auth.Rules[i]; r.match(name) {
+			rule = r
+			break
+		}
+	}
+
+	if rule == nil || len(rule.Principals) == 0 {
+		return 0
+	}
+
+	if !a.done {
+		a.done = true
+		for _, authenticator := range a.s.options.Auth.Authenticators {
+			if a.principal, a.authenticated = authenticator.Authenticate(a.r); a.authenticated {
+				break
+			}
+		}
+	}
+
+	if !a.authenticated {
+		return http.StatusUnauthorized
+	}
+
+	for _, principal := range rule.Principals {
+		if principal == "*" || principal == a.principal {
+			return 0
+		}
+	}
+
+	return http.StatusForbidden
+}
+
+// allowed reports whether the client is allowed to access the "name" file.
+func (a *authorizer) allowed(name string) bool {
+	return a.status(name) == 0
+}
+
+func (rule *AuthRule) match(name string) bool {
+	return matchAny(rule.Patterns, name)
+}
+
+// filterAllowed returns the push "targets" the client is allowed to access.
+func (s *fileServer) filterAllowed(auth *authorizer, r *http.Request, requestPath string, targets []string) []string {
+	mountPrefix := requestPrefix(r, requestPath)
+
+	allowed := targets[:0:0]
+	for _, target := range targets {
+		name := target
+		if idx := strings.IndexByte(name, '?'); idx != -1 {
+			name = name[:idx]
+		}
+
+		if auth.allowed(strings.TrimPrefix(name, mountPrefix)) {
+			allowed = append(allowed, target)
+		}
+	}
+
+	return allowed
+}
+
+// writeAuthError writes the 401 or 403 "status" of an `authorizer`,
+// 401 responses send a "WWW-Authenticate" challenge per `Authenticator`.
+func (s *fileServer) writeAuthError(w http.ResponseWriter, r *http.Request, status int) {
+	if status == http.StatusUnauthorized {
+		for _, authenticator := range s.options.Auth.Authenticators {
+			w.Header().Add("WWW-Authenticate", authenticator.Challenge())
+		}
+	}
+
+	s.writeError(w, r, status)
+}
+
+// basicAuth is the HTTP Basic `Authenticator`, see `BasicAuth`.
+type basicAuth struct {
+	realm string
+	users map[string]string // user name to htpasswd hash.
+
+	verified sync.Map // user name and password checksum of the verified bcrypt credentials.
+}
+
+// BasicAuth returns a HTTP Basic `Authenticator` of the "users",
+// a map of user names to their htpasswd-style password hashes:
+// bcrypt ("$2y$...") or SHA-1 ("{SHA}..."), e.g. "htpasswd -B" output.
+// The user name is the principal. See `Htpasswd` too.
+func BasicAuth(realm string, users map[string]string) Authenticator {
+	for user, hash := range users {
+		if !isBcrypt(hash) && !strings.HasPrefix(hash, "{SHA}") {
+			panic(fmt.Sprintf("BasicAuth: unsupported password hash of user %q", user))
+		}
+	}
+
+	return &basicAuth{realm: realm, users: users}
+}
+
+// Htpasswd returns a HTTP Basic `Authenticator` of the "user:hash" lines
+// of a htpasswd file, see `BasicAuth`.
+func Htpasswd(realm string, r io.Reader) (Authenticator, error) {
+	users := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("htpasswd: invalid line of user %q", user)
+		}
+
+		if !isBcrypt(hash) && !strings.HasPrefix(hash, "{SHA}") {
+			return nil, fmt.Errorf("htpasswd: unsupported password hash of user %q", user)
+		}
+
+		users[user] = hash
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &basicAuth{realm: realm, users: users}, nil
+}
+
+// HtpasswdFile is like `Htpasswd` but it reads the "filename" file.
+func HtpasswdFile(realm, filename string) (Authenticator, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Htpasswd(realm, f)
+}
+
+func (a *basicAuth) Authenticate(r *http.Request) (string, bool) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+
+	hash, ok := a.users[user]
+	if !ok {
+		return "", false
+	}
+
+	if strings.HasPrefix(hash, "{SHA}") {
+		sum := sha1.Sum([]byte(password))
+		expected := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) != 1 {
+			return "", false
+		}
+
+		return user, true
+	}
+
+	// bcrypt is slow by design, do not verify the same credentials on each request.
+	sum := sha256.Sum256([]byte(user + ":" + password))
+	if v, ok := a.verified.Load(user); ok && v.([sha256.Size]byte) == sum {
+		return user, true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return "", false
+	}
+
+	a.verified.Store(user, sum)
+	return user, true
+}
+
+func (a *basicAuth) Challenge() string {
+	return "Basic realm=" + strconv.Quote(a.realm) + `, charset="UTF-8"`
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// bearerTokens is the bearer token `Authenticator`, see `BearerTokens`.
+type bearerTokens struct {
+	realm  string
+	tokens map[[sha256.Size]byte]string // token checksum to principal.
+}
+
+// BearerTokens returns an `Authenticator` of the "Authorization: Bearer <token>"
+// request header, the "tokens" map the valid tokens to their principals.
+func BearerTokens(realm string, tokens map[string]string) Authenticator {
+	a := &bearerTokens{realm: realm, tokens: make(map[[sha256.Size]byte]string, len(tokens))}
+	for token, principal := range tokens {
+		// keep the checksums, so the lookup does not leak the tokens through timing.
+		a.tokens[sha256.Sum256([]byte(token))] = principal
+	}
+
+	return a
+}
+
+func (a *bearerTokens) Authenticate(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	principal, ok := a.tokens[sha256.Sum256([]byte(strings.TrimSpace(token)))]
+	return principal, ok
+}
+
+func (a *bearerTokens) Challenge() string {
+	return "Bearer realm=" + strconv.Quote(a.realm)
+}
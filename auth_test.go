@@ -0,0 +1,127 @@
+package httpfs
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAuthRules(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"public.txt":         "public",
+		"private/report.pdf": "report",
+		"private/login.txt":  "login",
+	})
+
+	opts := DefaultOptions
+	opts.Auth = Auth{
+		Authenticators: []Authenticator{
+			BasicAuth("files", map[string]string{"alice": "{SHA}" + sha1Base64("secret")}),
+			BearerTokens("files", map[string]string{"token": "bob"}),
+		},
+		Rules: []AuthRule{
+			{Patterns: []string{"/private/login.txt"}},
+			{Patterns: []string{"/private/*"}, Principals: []string{"alice"}},
+		},
+	}
+	h := FileServer(http.Dir(root), opts)
+
+	tests := []struct {
+		target string
+		header []string
+		status int
+	}{
+		{"/public.txt", nil, http.StatusOK},
+		{"/private/login.txt", nil, http.StatusOK},
+		{"/private/report.pdf", nil, http.StatusUnauthorized},
+		{"/private//report.pdf", nil, http.StatusUnauthorized},
+		{"/x/../private/report.pdf", nil, http.StatusUnauthorized},
+		{"/private/./report.pdf", nil, http.StatusUnauthorized},
+		{"//private/report.pdf", nil, http.StatusUnauthorized},
+		{"/private/report.pdf", []string{"Authorization", "Basic " + basic("alice", "wrong")}, http.StatusUnauthorized},
+		{"/private/report.pdf", []string{"Authorization", "Basic " + basic("alice", "secret")}, http.StatusOK},
+		{"/private/report.pdf", []string{"Authorization", "Bearer token"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		rec := serveTest(h, http.MethodGet, tt.target, tt.header...)
+		if rec.Code != tt.status {
+			t.Errorf("%s %v: expected status %d but got %d", tt.target, tt.header, tt.status, rec.Code)
+		}
+
+		if rec.Code == http.StatusUnauthorized && len(rec.Header().Values("WWW-Authenticate")) != 2 {
+			t.Errorf("%s: expected a challenge per authenticator but got %v", tt.target, rec.Header().Values("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestAuthMethodHandlers(t *testing.T) {
+	root := testDir(t, map[string]string{"private/report.pdf": "report"})
+
+	var called int
+	put := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	opts := DefaultOptions
+	opts.MethodHandlers = map[string]http.Handler{http.MethodPut: put}
+	opts.Auth = Auth{
+		Authenticators: []Authenticator{BearerTokens("files", map[string]string{"token": "bob"})},
+		Rules:          []AuthRule{{Patterns: []string{"/private/*"}, Principals: []string{"*"}}},
+	}
+	h := FileServer(http.Dir(root), opts)
+
+	if rec := serveTest(h, http.MethodPut, "/private/report.pdf"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d but got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	if rec := serveTest(h, http.MethodOptions, "/private/report.pdf"); rec.Code != http.StatusNoContent {
+		t.Fatalf("OPTIONS: expected status %d but got %d", http.StatusNoContent, rec.Code)
+	}
+
+	if rec := serveTest(h, http.MethodPut, "/private/report.pdf", "Authorization", "Bearer token"); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d", http.StatusCreated, rec.Code)
+	}
+
+	opts.Authorize = func(r *http.Request, name string) Decision {
+		return Denied(http.StatusForbidden, "read-only")
+	}
+	h = FileServer(http.Dir(root), opts)
+
+	if rec := serveTest(h, http.MethodPut, "/private/report.pdf", "Authorization", "Bearer token"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d but got %d", http.StatusForbidden, rec.Code)
+	}
+
+	if called != 1 {
+		t.Fatalf("expected the handler to be called once but called %d times", called)
+	}
+}
+
+func TestHtpasswd(t *testing.T) {
+	a, err := Htpasswd("files", strings.NewReader("# users\nalice:{SHA}"+sha1Base64("secret")+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth("alice", "secret")
+	if principal, ok := a.Authenticate(r); !ok || principal != "alice" {
+		t.Fatalf("expected alice to be authenticated but got %q, %v", principal, ok)
+	}
+
+	if _, err = Htpasswd("files", strings.NewReader("alice:$apr1$invalid\n")); err == nil {
+		t.Fatal("expected an error on an unsupported hash")
+	}
+}
+
+func basic(user, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+}
+
+func sha1Base64(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
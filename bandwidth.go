@@ -0,0 +1,192 @@
+package httpfs
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// LimitScope describes who shares a limit. See `BandwidthLimit`.
+type LimitScope uint8
+
+const (
+	// LimitPerRequest limits each response on its own. This is the zero value.
+	LimitPerRequest LimitScope = iota
+	// LimitPerClient limits all the responses of a client together,
+	// e.g. parallel range requests of a download manager.
+	// See `BandwidthLimit.ClientKey`.
+	LimitPerClient
+	// LimitGlobal limits all the responses of the `FileServer` together.
+	LimitGlobal
+)
+
+// DefaultLimitIdleTimeout is the default `BandwidthLimit.IdleTimeout`.
+const DefaultLimitIdleTimeout = 5 * time.Minute
+
+// BandwidthLimit limits the bytes per second of the served files.
+// See `Options.Bandwidth`.
+type BandwidthLimit struct {
+	// Scope is the scope of the limit, per request, per client or global.
+	Scope LimitScope
+	// Limit is the bytes sent per second, e.g. 500 * KB.
+	Limit float64
+	// Burst is the maximum bytes sent at once, defaults to the `Limit`.
+	Burst int
+	// Patterns holds the glob patterns (see `path.Match`) of the files
+	// this limit applies to, matched like the `Options.Deny` ones,
+	// e.g. "*.iso". Empty matches all files.
+	Patterns []string
+	// ClientKey returns the key of the client of a `LimitPerClient` limit,
+	// e.g. an authenticated user name. Defaults to the remote IP address.
+	ClientKey func(r *http.Request) string
+	// IdleTimeout is the time after a client's last response
+	// that its `LimitPerClient` limiter is removed.
+	// Defaults to `DefaultLimitIdleTimeout`.
+	IdleTimeout time.Duration
+}
+
+func (l *BandwidthLimit) burst() int {
+	return burstOf(l.Limit, l.Burst)
+}
+
+// burstOf returns the "burst" or, if not positive,
+// the bytes of one second of the "limit".
+func burstOf(limit float64, burst int) int {
+	if burst > 0 {
+		return burst
+	}
+
+	if limit < 1 {
+		return 1
+	}
+
+	return int(limit)
+}
+
+func (l *BandwidthLimit) match(name string) bool {
+	return matchAny(l.Patterns, name)
+}
+
+// bandwidthLimiter holds the shared limiters of a `BandwidthLimit`.
+type bandwidthLimiter struct {
+	limit  BandwidthLimit
+	global *rate.Limiter // on LimitGlobal.
+
+	mu        sync.Mutex // protects the fields below.
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+// clientLimiter is the limiter of a `LimitPerClient` client.
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	active   int // the responses in progress.
+	lastUsed time.Time
+}
+
+func newBandwidthLimiter(limit BandwidthLimit) *bandwidthLimiter {
+	l := &bandwidthLimiter{limit: limit}
+	switch limit.Scope {
+	case LimitGlobal:
+		l.global = rate.NewLimiter(rate.Limit(limit.Limit), limit.burst())
+	case LimitPerClient:
+		l.clients = make(map[string]*clientLimiter)
+	}
+
+	return l
+}
+
+// acquire returns the limiter of a response to the "r" request,
+// the "release" function must be called after the response is sent.
+func (l *bandwidthLimiter) acquire(r *http.Request) (limiter *rate.Limiter, release func()) {
+	switch l.limit.Scope {
+	case LimitGlobal:
+		return l.global, func() {}
+	case LimitPerClient:
+	default:
+		return rate.NewLimiter(rate.Limit(l.limit.Limit), l.limit.burst()), func() {}
+	}
+
+	key := clientKey(l.limit.ClientKey, r)
+	now := time.Now()
+
+	l.mu.Lock()
+	l.sweep(now)
+	c, ok := l.clients[key]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(l.limit.Limit), l.limit.burst())}
+		l.clients[key] = c
+	}
+	c.active++
+	l.mu.Unlock()
+
+	return c.limiter, func() {
+		l.mu.Lock()
+		c.active--
+		c.lastUsed = time.Now()
+		l.mu.Unlock()
+	}
+}
+
+// sweep removes the limiters of the clients which are idle
+// for more than the `IdleTimeout`, at most once per `IdleTimeout`.
+func (l *bandwidthLimiter) sweep(now time.Time) {
+	idle := l.limit.IdleTimeout
+	if idle <= 0 {
+		idle = DefaultLimitIdleTimeout
+	}
+
+	if now.Sub(l.lastSweep) < idle {
+		return
+	}
+	l.lastSweep = now
+
+	for key, c := range l.clients {
+		if c.active == 0 && now.Sub(c.lastUsed) > idle {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// bandwidthLimiters returns the limiters of the `Options.Bandwidth` limits
+// which apply to the "name" file. The "release" function
+// must be called after the response is sent.
+func (s *fileServer) bandwidthLimiters(r *http.Request, name string) ([]*rate.Limiter, func()) {
+	var (
+		limiters []*rate.Limiter
+		releases []func()
+	)
+
+	for _, l := range s.bandwidth {
+		if !l.limit.match(name) {
+			continue
+		}
+
+		limiter, release := l.acquire(r)
+		limiters = append(limiters, limiter)
+		releases = append(releases, release)
+	}
+
+	return limiters, func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+}
+
+// clientKey returns the key of a per client limit,
+// the result of the "keyFunc" or the remote IP address.
+func clientKey(keyFunc func(r *http.Request) string, r *http.Request) string {
+	if keyFunc != nil {
+		return keyFunc(r)
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+
+	return r.RemoteAddr
+}
@@ -0,0 +1,116 @@
+package httpfs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func clientRequest(remoteAddr string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/a.iso", nil)
+	r.RemoteAddr = remoteAddr
+	return r
+}
+
+func TestBandwidthLimitScopes(t *testing.T) {
+	a1, a2, b := clientRequest("10.0.0.1:1000"), clientRequest("10.0.0.1:2000"), clientRequest("10.0.0.2:1000")
+
+	perRequest := newBandwidthLimiter(BandwidthLimit{Limit: 100})
+	l1, _ := perRequest.acquire(a1)
+	l2, _ := perRequest.acquire(a2)
+	if l1 == l2 {
+		t.Errorf("per request: expected a limiter per response")
+	}
+
+	if l1.Burst() != 100 {
+		t.Errorf("per request: expected the burst to default to the limit but got %d", l1.Burst())
+	}
+
+	perClient := newBandwidthLimiter(BandwidthLimit{Scope: LimitPerClient, Limit: 100, Burst: 10})
+	l1, _ = perClient.acquire(a1)
+	l2, _ = perClient.acquire(a2)
+	l3, _ := perClient.acquire(b)
+	if l1 != l2 {
+		t.Errorf("per client: expected the responses of a client to share a limiter")
+	}
+
+	if l1 == l3 {
+		t.Errorf("per client: expected a limiter per client")
+	}
+
+	if l1.Burst() != 10 {
+		t.Errorf("per client: expected burst 10 but got %d", l1.Burst())
+	}
+
+	byUser := newBandwidthLimiter(BandwidthLimit{Scope: LimitPerClient, Limit: 100, ClientKey: func(r *http.Request) string {
+		return "user"
+	}})
+	l1, _ = byUser.acquire(a1)
+	l3, _ = byUser.acquire(b)
+	if l1 != l3 {
+		t.Errorf("per client key: expected the clients of the same key to share a limiter")
+	}
+
+	global := newBandwidthLimiter(BandwidthLimit{Scope: LimitGlobal, Limit: 100})
+	l1, _ = global.acquire(a1)
+	l3, _ = global.acquire(b)
+	if l1 != l3 {
+		t.Errorf("global: expected all the responses to share a limiter")
+	}
+}
+
+func TestBandwidthLimitIdle(t *testing.T) {
+	l := newBandwidthLimiter(BandwidthLimit{Scope: LimitPerClient, Limit: 100, IdleTimeout: 10 * time.Millisecond})
+
+	_, releaseIdle := l.acquire(clientRequest("10.0.0.1:1000"))
+	releaseIdle()
+	_, releaseActive := l.acquire(clientRequest("10.0.0.2:1000"))
+	defer releaseActive()
+
+	time.Sleep(20 * time.Millisecond)
+	l.acquire(clientRequest("10.0.0.3:1000"))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.clients["10.0.0.1"]; ok {
+		t.Errorf("expected the idle client to be removed")
+	}
+
+	if _, ok := l.clients["10.0.0.2"]; !ok {
+		t.Errorf("expected the client of a response in progress to be kept")
+	}
+
+	if len(l.clients) != 2 {
+		t.Errorf("expected 2 clients but got %d", len(l.clients))
+	}
+}
+
+func TestBandwidth(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"a.iso": strings.Repeat("x", 300000),
+		"a.txt": "a",
+	})
+
+	opts := DefaultOptions
+	opts.Compress = false
+	opts.Bandwidth = []BandwidthLimit{
+		{Scope: LimitPerClient, Limit: 1e7, Patterns: []string{"*.iso"}},
+		{Scope: LimitGlobal, Limit: 2e7},
+	}
+	h := FileServer(http.Dir(root), opts)
+
+	s := h.(*fileServer)
+	if limiters, release := s.bandwidthLimiters(clientRequest("10.0.0.1:1000"), "/a.txt"); len(limiters) != 1 {
+		t.Errorf("/a.txt: expected the global limit only but got %d limits", len(limiters))
+	} else {
+		release()
+	}
+
+	rec := serveTest(h, http.MethodGet, "/a.iso")
+	if rec.Code != http.StatusOK || rec.Body.Len() != 300000 {
+		t.Errorf("expected the whole file but got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+}
@@ -3,6 +3,7 @@ package httpfs
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -65,32 +66,71 @@ func MustCache(fs http.FileSystem, options CacheOptions) http.FileSystem {
 
 // Cache returns a http.FileSystem which serves in-memory cached (compressed) files.
 // Look `Verbose` function to print out information while in development status.
+// See `Rebuild` to refresh the cached files when the "fs" is modified.
 func Cache(fs http.FileSystem, options CacheOptions) (http.FileSystem, error) {
+	c := &cacheFS{source: fs, options: options}
+	if err := c.build(); err != nil {
+		return fs, err
+	}
+
+	return c, nil
+}
+
+// ErrNotCached is returned by `Rebuild` when
+// the given file system was not created by `Cache`.
+var ErrNotCached = errors.New("not a cached file system")
+
+// Rebuild reads and compresses the files of a `Cache` file system
+// from its source file system again, e.g. after a new deployment.
+// The previous files are served until the rebuild is completed.
+// The `FileServer` reloads anything it derives from the cached files,
+// e.g. the `DeployFiles`, on its next request.
+func Rebuild(fs http.FileSystem) error {
+	c, ok := fs.(*cacheFS)
+	if !ok {
+		return ErrNotCached
+	}
+
+	return c.build()
+}
+
+// build reads and compresses the source files
+// and replaces the cached ones on success.
+func (c *cacheFS) build() error {
 	start := time.Now()
 
-	names, err := findNames(fs, "/")
+	names, err := findNames(c.source, "/")
 	if err != nil {
-		return fs, err
+		return err
 	}
 
 	sort.Slice(names, func(i, j int) bool {
 		return strings.Count(names[j], "/") > strings.Count(names[i], "/")
 	})
 
-	dirs, err := findDirs(fs, names)
+	dirs, err := findDirs(c.source, names)
 	if err != nil {
-		return fs, err
+		return err
 	}
 
-	files, err := cacheFiles(context.Background(), fs, names,
-		options.Encodings, options.CompressMinSize, options.CompressIgnore)
+	files, err := cacheFiles(context.Background(), c.source, names,
+		c.options.Encodings, c.options.CompressMinSize, c.options.CompressIgnore)
 	if err != nil {
-		return fs, err
+		return err
 	}
 
 	ttc := time.Since(start)
-	c := &cacheFS{ttc: ttc, n: len(names), dirs: dirs, files: files, algs: options.Encodings}
-	return c, nil
+
+	c.mu.Lock()
+	c.ttc = ttc
+	c.n = len(names)
+	c.dirs = dirs
+	c.files = files
+	c.algs = c.options.Encodings
+	c.version++
+	c.mu.Unlock()
+
+	return nil
 }
 
 // VerboseFull if enabled then Verbose will print each file's sizes.
@@ -108,6 +148,9 @@ func Verbose(fs http.FileSystem) {
 }
 
 func verboseCacheFS(fs *cacheFS) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
 	var (
 		totalLength             int64
 		totalCompressedLength   = make(map[string]int64)
@@ -150,16 +193,36 @@ func verboseCacheFS(fs *cacheFS) {
 }
 
 type cacheFS struct {
+	source  http.FileSystem
+	options CacheOptions
+
+	mu  sync.RWMutex  // protects the fields below, see `Rebuild`.
 	ttc time.Duration // time to complete
 	n   int           // total files
 
-	dirs  map[string]*dir
-	files fileMap
-	algs  []string
+	dirs    map[string]*dir
+	files   fileMap
+	algs    []string
+	version uint64 // incremented on each build.
 }
 
 var _ http.FileSystem = (*cacheFS)(nil)
 
+// versionedFS is implemented by file systems which
+// can change their contents at runtime, see `Rebuild`.
+type versionedFS interface {
+	cacheVersion() uint64
+}
+
+var _ versionedFS = (*cacheFS)(nil)
+
+func (c *cacheFS) cacheVersion() uint64 {
+	c.mu.RLock()
+	v := c.version
+	c.mu.RUnlock()
+	return v
+}
+
 // Open returns the http.File based on "name".
 // If file, it always returns a cached file of uncompressed data.
 // See `Ropen` too.
@@ -175,11 +238,16 @@ func (c *cacheFS) Open(name string) (http.File, error) {
 		name = "/" + name
 	}
 
-	if d, ok := c.dirs[name]; ok {
+	c.mu.RLock()
+	d, isDir := c.dirs[name]
+	f, isFile := c.files[name]
+	c.mu.RUnlock()
+
+	if isDir {
 		return d, nil
 	}
 
-	if f, ok := c.files[name]; ok {
+	if isFile {
 		return f.Get("")
 	}
 
@@ -210,12 +278,18 @@ func (c *cacheFS) Ropen(name string, r *http.Request) (http.File, error) {
 		name = "/" + name
 	}
 
-	if d, ok := c.dirs[name]; ok {
+	c.mu.RLock()
+	d, isDir := c.dirs[name]
+	f, isFile := c.files[name]
+	algs := c.algs
+	c.mu.RUnlock()
+
+	if isDir {
 		return d, nil
 	}
 
-	if f, ok := c.files[name]; ok {
-		encoding, _ := compress.GetEncoding(r, c.algs)
+	if isFile {
+		encoding, _ := compress.GetEncoding(r, algs)
 		return f.Get(encoding)
 	}
 
@@ -360,6 +434,7 @@ type file struct {
 	name          string
 	baseName      string
 	info          os.FileInfo
+	assets        *indexAssets // shared by the store and its files, see `Options.PushAssets`.
 }
 
 var _ http.File = (*file)(nil)
@@ -371,6 +446,7 @@ func newFile(name string, fi os.FileInfo, algs map[string][]byte) *file {
 		baseName: path.Base(name),
 		info:     fi,
 		algs:     algs,
+		assets:   new(indexAssets),
 	}
 }
 
@@ -391,6 +467,7 @@ func (f *file) Get(alg string) (http.File, error) {
 			info:       f.info,
 			alg:        alg,
 			ReadSeeker: bytes.NewReader(contents),
+			assets:     f.assets,
 		}, nil
 	}
 
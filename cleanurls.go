@@ -0,0 +1,119 @@
+package httpfs
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// TrailingSlashPolicy describes how the `FileServer` treats
+// a trailing slash on request paths. See `Options.TrailingSlash`.
+type TrailingSlashPolicy uint8
+
+const (
+	// TrailingSlashIgnore serves the request paths as they are.
+	// This is the zero value.
+	TrailingSlashIgnore TrailingSlashPolicy = iota
+	// TrailingSlashAdd redirects directory requests without a trailing slash
+	// to their slash-suffixed path, e.g. "/docs/intro" to "/docs/intro/".
+	TrailingSlashAdd
+	// TrailingSlashStrip redirects request paths with a trailing slash
+	// to their slash-less path, e.g. "/docs/intro/" to "/docs/intro".
+	TrailingSlashStrip
+)
+
+// openExtension tries to open the "name" followed by
+// each one of the `Options.Extensions`, in order.
+// It returns the opened file and its name on success.
+// Failures other than a missing file are reported.
+func (s *fileServer) openExtension(r *http.Request, name string) (http.File, string, bool) {
+	if strings.HasSuffix(name, "/") {
+		return nil, "", false
+	}
+
+	for _, ext := range s.options.Extensions {
+		extName := name + ext
+		if s.options.hiddenStatus(extName) != 0 {
+			continue
+		}
+
+		f, err := s.open(extName, r)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				s.reportError(r, extName, StageOpen, err)
+			}
+			continue
+		}
+
+		if info, err := f.Stat(); err != nil || info.IsDir() {
+			f.Close()
+			s.reportError(r, extName, StageStat, err)
+			continue
+		}
+
+		return f, extName, true
+	}
+
+	return nil, "", false
+}
+
+// hasExtension reports whether the "name" is the clean URL of a page,
+// i.e. the "name" followed by one of the `Options.Extensions` is a file.
+func (s *fileServer) hasExtension(name string) bool {
+	if strings.HasSuffix(name, "/") {
+		return false
+	}
+
+	for _, ext := range s.options.Extensions {
+		if info, err := stat(s.fs, name+ext); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cleanURL returns the extension-less path of the "name"
+// if it ends with one of the `Options.Extensions`
+// and the extension-less path does not exist itself.
+func (s *fileServer) cleanURL(r *http.Request, name string) (string, bool) {
+	ext := path.Ext(name)
+	if ext == "" {
+		return "", false
+	}
+
+	for _, e := range s.options.Extensions {
+		if !strings.EqualFold(e, ext) {
+			continue
+		}
+
+		clean := strings.TrimSuffix(name, ext)
+		if clean == "" || strings.HasSuffix(clean, "/") {
+			return "", false
+		}
+
+		if f, err := s.open(clean, r); err == nil {
+			// the extension-less path is a different resource.
+			f.Close()
+			return "", false
+		}
+
+		return clean, true
+	}
+
+	return "", false
+}
+
+// redirectToDir redirects an index file request to its directory,
+// respecting the `Options.TrailingSlash` policy.
+func (s *fileServer) redirectToDir(w http.ResponseWriter, r *http.Request, name string) {
+	dir := path.Dir(name)
+	if s.options.TrailingSlash == TrailingSlashStrip && dir != "/" {
+		localRedirect(w, r, "../"+path.Base(dir))
+		return
+	}
+
+	localRedirect(w, r, "./")
+}
@@ -0,0 +1,173 @@
+package httpfs
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ConcurrencyLimit limits the simultaneous downloads of the served files.
+// See `Options.Concurrency`.
+type ConcurrencyLimit struct {
+	// Scope is the scope of the limit, `LimitPerClient` or `LimitGlobal`.
+	// `LimitPerRequest` is not a valid scope of a concurrency limit.
+	Scope LimitScope
+	// Max is the maximum number of simultaneous downloads.
+	Max int
+	// Patterns holds the glob patterns (see `path.Match`) of the files
+	// this limit applies to, matched like the `Options.Deny` ones,
+	// e.g. "*.iso". Empty matches all files.
+	Patterns []string
+	// ClientKey returns the key of the client of a `LimitPerClient` limit,
+	// e.g. an authenticated user name. Defaults to the remote IP address.
+	ClientKey func(r *http.Request) string
+	// QueueTimeout, if positive, queues the exceeding downloads
+	// until a download is completed or the timeout is passed.
+	// Otherwise (or on timeout) they are rejected with 429 Too Many Requests.
+	QueueTimeout time.Duration
+	// RetryAfter is the "Retry-After" header value of the rejected downloads,
+	// rounded to seconds. Defaults to 1 second.
+	RetryAfter time.Duration
+}
+
+func (l *ConcurrencyLimit) match(name string) bool {
+	return matchAny(l.Patterns, name)
+}
+
+// concurrencyLimiter holds the download slots of a `ConcurrencyLimit`.
+type concurrencyLimiter struct {
+	limit  ConcurrencyLimit
+	global chan struct{} // on LimitGlobal.
+
+	mu      sync.Mutex // protects the clients.
+	clients map[string]*clientSlots
+}
+
+// clientSlots are the download slots of a `LimitPerClient` client,
+// removed when the client has no downloads in progress or queued.
+type clientSlots struct {
+	slots chan struct{}
+	users int // the downloads in progress and queued.
+}
+
+func newConcurrencyLimiter(limit ConcurrencyLimit) *concurrencyLimiter {
+	l := &concurrencyLimiter{limit: limit}
+	if limit.Scope == LimitGlobal {
+		l.global = make(chan struct{}, limit.Max)
+	} else {
+		l.clients = make(map[string]*clientSlots)
+	}
+
+	return l
+}
+
+// acquire takes a download slot, waiting for the `QueueTimeout` if there is none.
+// It reports whether a slot was taken, the "release" function must be called
+// after the download is completed.
+func (l *concurrencyLimiter) acquire(r *http.Request) (release func(), ok bool) {
+	if l.global != nil {
+		if !l.wait(r, l.global) {
+			return nil, false
+		}
+
+		return func() { <-l.global }, true
+	}
+
+	key := clientKey(l.limit.ClientKey, r)
+
+	l.mu.Lock()
+	c, exists := l.clients[key]
+	if !exists {
+		c = &clientSlots{slots: make(chan struct{}, l.limit.Max)}
+		l.clients[key] = c
+	}
+	c.users++
+	l.mu.Unlock()
+
+	done := func() {
+		l.mu.Lock()
+		if c.users--; c.users == 0 {
+			delete(l.clients, key)
+		}
+		l.mu.Unlock()
+	}
+
+	if !l.wait(r, c.slots) {
+		done()
+		return nil, false
+	}
+
+	return func() {
+		<-c.slots
+		done()
+	}, true
+}
+
+// wait takes one of the "slots", waiting up to the `QueueTimeout`
+// or until the client goes away.
+func (l *concurrencyLimiter) wait(r *http.Request, slots chan struct{}) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if l.limit.QueueTimeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(l.limit.QueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// acquireDownload takes a download slot of each `Options.Concurrency` limit
+// which applies to the "name" file, the per client ones first.
+// If a limit is exceeded it responds with
+// 429 Too Many Requests and reports false. The "release" function must be called
+// after the download is completed.
+func (s *fileServer) acquireDownload(w http.ResponseWriter, r *http.Request, name string) (func(), bool) {
+	var releases []func()
+	release := func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+
+	for _, l := range s.concurrency {
+		if !l.limit.match(name) {
+			continue
+		}
+
+		rel, ok := l.acquire(r)
+		if !ok {
+			release()
+
+			if r.Context().Err() != nil {
+				return nil, false // the client went away while queued.
+			}
+
+			retryAfter := int64(l.limit.RetryAfter.Round(time.Second) / time.Second)
+			if retryAfter <= 0 {
+				retryAfter = 1
+			}
+
+			w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+			s.writeError(w, r, http.StatusTooManyRequests)
+			return nil, false
+		}
+
+		releases = append(releases, rel)
+	}
+
+	return release, true
+}
@@ -0,0 +1,67 @@
+package httpfs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimits(t *testing.T) {
+	root := testDir(t, map[string]string{"a.iso": "iso"})
+
+	clientKey := func(r *http.Request) string { return r.Header.Get("X-Client") }
+	opts := DefaultOptions
+	opts.Concurrency = []ConcurrencyLimit{
+		{Scope: LimitGlobal, Max: 2, RetryAfter: 3 * time.Second},
+		{Scope: LimitPerClient, Max: 1, ClientKey: clientKey, QueueTimeout: 200 * time.Millisecond},
+	}
+	s := FileServer(http.Dir(root), opts).(*fileServer)
+
+	acquire := func(client string) (func(), *httptest.ResponseRecorder, bool) {
+		r := httptest.NewRequest(http.MethodGet, "/a.iso", nil)
+		r.Header.Set("X-Client", client)
+		w := httptest.NewRecorder()
+		release, ok := s.acquireDownload(w, r, "/a.iso")
+		return release, w, ok
+	}
+
+	releaseA, _, ok := acquire("a")
+	if !ok {
+		t.Fatal("expected the first download of a to start")
+	}
+
+	// a second download of "a" is queued on its own limit...
+	queued := make(chan bool)
+	go func() {
+		release, _, ok := acquire("a")
+		if ok {
+			release()
+		}
+		queued <- ok
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// ...without holding a global slot, so "b" can start.
+	releaseB, _, ok := acquire("b")
+	if !ok {
+		t.Fatal("expected the download of b to start while a is queued")
+	}
+
+	// the global limit is reached.
+	if _, w, ok := acquire("c"); ok || w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "3" {
+		t.Fatalf("expected 429 with Retry-After 3 but got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	releaseA()
+	if !<-queued {
+		t.Fatal("expected the queued download of a to start after the first one")
+	}
+
+	releaseB()
+	if release, _, ok := acquire("c"); !ok {
+		t.Fatal("expected the download of c to start after the rest")
+	} else {
+		release()
+	}
+}
@@ -0,0 +1,197 @@
+package httpfs
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS holds the Cross-Origin Resource Sharing settings
+// for a set of files. See `Options.CORS`.
+type CORS struct {
+	// Patterns holds the glob patterns (see `path.Match`) of the request paths
+	// this rule applies to, matched like the `Options.Deny` ones,
+	// e.g. "*.woff2" or "/data/*". Empty matches all files.
+	Patterns []string
+	// AllowOrigins is the list of the allowed origins,
+	// e.g. "https://example.com". An origin may contain a single "*" wildcard,
+	// e.g. "https://*.example.com", a sole "*" allows any origin.
+	AllowOrigins []string
+	// AllowOriginsRegexp, if not nil, allows the origins that match this expression too.
+	AllowOriginsRegexp *regexp.Regexp
+	// AllowMethods is the list of methods allowed on preflight requests.
+	// Defaults to GET and HEAD.
+	AllowMethods []string
+	// AllowHeaders is the list of request headers allowed on preflight requests,
+	// a sole "*" allows any header.
+	AllowHeaders []string
+	// ExposeHeaders is the list of response headers the client is allowed to read.
+	ExposeHeaders []string
+	// AllowCredentials allows requests with cookies and authorization headers.
+	// The origin is always reflected (instead of a "*") when enabled.
+	AllowCredentials bool
+	// MaxAge is the time the result of a preflight request can be cached by the client.
+	MaxAge time.Duration
+}
+
+func (c *CORS) matchPath(name string) bool {
+	return matchAny(c.Patterns, name)
+}
+
+func (c *CORS) allowOrigin(origin string) bool {
+	for _, allowed := range c.AllowOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+
+		if idx := strings.IndexByte(allowed, '*'); idx != -1 {
+			prefix, suffix := allowed[:idx], allowed[idx+1:]
+			if len(origin) >= len(prefix)+len(suffix) &&
+				strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+
+	return c.AllowOriginsRegexp != nil && c.AllowOriginsRegexp.MatchString(origin)
+}
+
+func (c *CORS) allowAnyOrigin() bool {
+	for _, allowed := range c.AllowOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *CORS) allowMethod(method string) bool {
+	if len(c.AllowMethods) == 0 {
+		return method == http.MethodGet || method == http.MethodHead
+	}
+
+	for _, allowed := range c.AllowMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowHeaders reports whether all the comma-separated "requested" headers are allowed.
+func (c *CORS) allowHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+
+		allowed := false
+		for _, h := range c.AllowHeaders {
+			if h == "*" || strings.EqualFold(h, header) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// corsRule returns the first `Options.CORS` rule which applies to the "name", if any.
+func (s *fileServer) corsRule(name string) *CORS {
+	for i := range s.options.CORS {
+		if c := &s.options.CORS[i]; c.matchPath(name) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// serveCORS writes the CORS response headers of the "name"
+// and answers the preflight requests.
+// It reports whether the request was a preflight one and it was handled.
+func (s *fileServer) serveCORS(w http.ResponseWriter, r *http.Request, name string) bool {
+	c := s.corsRule(name)
+	if c == nil {
+		return false
+	}
+
+	h := w.Header()
+	origin := r.Header.Get("Origin")
+
+	addVary(h, "Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	if preflight {
+		addVary(h, "Access-Control-Request-Method")
+		addVary(h, "Access-Control-Request-Headers")
+	}
+
+	if origin == "" || !c.allowOrigin(origin) {
+		// Not a cross-origin request or not an allowed one,
+		// preflight requests continue as plain OPTIONS ones.
+		return false
+	}
+
+	if c.allowAnyOrigin() && !c.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if len(c.ExposeHeaders) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposeHeaders, ", "))
+		}
+
+		return false
+	}
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	requestedHeaders := r.Header.Get("Access-Control-Request-Headers")
+	if !c.allowMethod(method) || !c.allowHeaders(requestedHeaders) {
+		// continue as a plain OPTIONS request, the client will fail the preflight.
+		h.Del("Access-Control-Allow-Origin")
+		h.Del("Access-Control-Allow-Credentials")
+		return false
+	}
+
+	h.Set("Access-Control-Allow-Methods", method)
+	if requestedHeaders != "" {
+		h.Set("Access-Control-Allow-Headers", requestedHeaders)
+	}
+
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.FormatInt(int64(c.MaxAge/time.Second), 10))
+	}
+
+	h.Set("Content-Length", "0")
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// addVary adds the "value" to the "Vary" response header, if not already there.
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field == "*" || strings.EqualFold(field, value) {
+				return
+			}
+		}
+	}
+
+	h.Add("Vary", value)
+}
@@ -0,0 +1,123 @@
+package httpfs
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"font.woff2":  "font",
+		"data/a.json": "{}",
+		"page.html":   "page",
+	})
+
+	opts := DefaultOptions
+	opts.CORS = []CORS{
+		{
+			Patterns:     []string{"*.woff2"},
+			AllowOrigins: []string{"*"},
+		},
+		{
+			Patterns:         []string{"/data/*"},
+			AllowOrigins:     []string{"https://*.example.com"},
+			AllowHeaders:     []string{"X-Token"},
+			ExposeHeaders:    []string{"ETag"},
+			AllowCredentials: true,
+			MaxAge:           time.Hour,
+		},
+	}
+	h := FileServer(http.Dir(root), opts)
+
+	tests := []struct {
+		method string
+		target string
+		header []string
+		status int
+		expect map[string]string
+	}{
+		{http.MethodGet, "/font.woff2", []string{"Origin", "https://a.com"}, http.StatusOK, map[string]string{
+			"Access-Control-Allow-Origin": "*",
+			"Vary":                        "Origin",
+		}},
+		{http.MethodGet, "/data/a.json", []string{"Origin", "https://app.example.com"}, http.StatusOK, map[string]string{
+			"Access-Control-Allow-Origin":      "https://app.example.com",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Expose-Headers":    "ETag",
+		}},
+		{http.MethodGet, "/data/a.json", []string{"Origin", "https://example.com.evil.com"}, http.StatusOK, map[string]string{
+			"Access-Control-Allow-Origin": "",
+		}},
+		{http.MethodGet, "/page.html", []string{"Origin", "https://app.example.com"}, http.StatusOK, map[string]string{
+			"Access-Control-Allow-Origin": "",
+			"Vary":                        "",
+		}},
+		// preflight requests.
+		{http.MethodOptions, "/data/a.json", []string{
+			"Origin", "https://app.example.com",
+			"Access-Control-Request-Method", "GET",
+			"Access-Control-Request-Headers", "x-token",
+		}, http.StatusNoContent, map[string]string{
+			"Access-Control-Allow-Origin":  "https://app.example.com",
+			"Access-Control-Allow-Methods": "GET",
+			"Access-Control-Allow-Headers": "x-token",
+			"Access-Control-Max-Age":       "3600",
+		}},
+		{http.MethodOptions, "/data/a.json", []string{
+			"Origin", "https://app.example.com",
+			"Access-Control-Request-Method", "DELETE",
+		}, http.StatusNoContent, map[string]string{
+			"Access-Control-Allow-Origin":  "",
+			"Access-Control-Allow-Methods": "",
+			"Allow":                        "GET, HEAD, OPTIONS",
+		}},
+		{http.MethodOptions, "/data/a.json", []string{
+			"Origin", "https://app.example.com",
+			"Access-Control-Request-Method", "GET",
+			"Access-Control-Request-Headers", "X-Other",
+		}, http.StatusNoContent, map[string]string{
+			"Access-Control-Allow-Origin":  "",
+			"Access-Control-Allow-Headers": "",
+		}},
+	}
+
+	for _, tt := range tests {
+		rec := serveTest(h, tt.method, tt.target, tt.header...)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s %v: expected status %d but got %d", tt.method, tt.target, tt.header, tt.status, rec.Code)
+		}
+
+		for key, expected := range tt.expect {
+			if got := rec.Header().Get(key); got != expected {
+				t.Errorf("%s %s %v: expected %s %q but got %q", tt.method, tt.target, tt.header, key, expected, got)
+			}
+		}
+	}
+}
+
+func TestCORSPreflightAuth(t *testing.T) {
+	root := testDir(t, map[string]string{"private/a.json": "{}"})
+
+	opts := DefaultOptions
+	opts.CORS = []CORS{{AllowOrigins: []string{"https://app.example.com"}, AllowHeaders: []string{"Authorization"}}}
+	opts.Auth = Auth{
+		Authenticators: []Authenticator{BearerTokens("files", map[string]string{"token": "bob"})},
+		Rules:          []AuthRule{{Patterns: []string{"/private/*"}, Principals: []string{"*"}}},
+	}
+	h := FileServer(http.Dir(root), opts)
+
+	// preflight requests carry no credentials.
+	rec := serveTest(h, http.MethodOptions, "/private/a.json",
+		"Origin", "https://app.example.com",
+		"Access-Control-Request-Method", "GET",
+		"Access-Control-Request-Headers", "authorization")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Fatalf("expected the preflight to be answered but got %d %v", rec.Code, rec.Header())
+	}
+
+	rec = serveTest(h, http.MethodGet, "/private/a.json", "Origin", "https://app.example.com")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Fatalf("expected 401 with the CORS headers but got %d %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,107 @@
+package httpfs
+
+import (
+	"net/http"
+	"path"
+)
+
+// DecisionAction is the action of a `Decision`.
+type DecisionAction uint8
+
+// The available decision actions.
+const (
+	// ActionAllow serves the request. This is the zero value.
+	ActionAllow DecisionAction = iota
+	// ActionDeny responds with the decision's status code, 403 Forbidden by default.
+	ActionDeny
+	// ActionRedirect redirects the client to the decision's location.
+	ActionRedirect
+	// ActionRewrite serves the decision's file name instead of the requested one.
+	ActionRewrite
+)
+
+// Decision is the result of an `Options.Authorize` callback.
+// The zero value allows the request.
+type Decision struct {
+	Action DecisionAction
+	// Status is the status code of a denied or a redirected request,
+	// defaults to 403 Forbidden and 302 Found respectively.
+	Status int
+	// Reason describes why a request was denied, see `AccessLogEntry.Reason`.
+	Reason string
+	// Location is the URL of a redirect.
+	Location string
+	// Name is the file name of a rewrite, e.g. "/maintenance.html".
+	Name string
+}
+
+// Allowed returns a `Decision` which serves the request.
+func Allowed() Decision {
+	return Decision{}
+}
+
+// Denied returns a `Decision` which responds with the "status" code,
+// e.g. http.StatusForbidden. The "reason" is logged, not sent to the client.
+func Denied(status int, reason string) Decision {
+	return Decision{Action: ActionDeny, Status: status, Reason: reason}
+}
+
+// Redirected returns a `Decision` which redirects the client
+// to the "location" URL with the "status" code, e.g. http.StatusFound.
+func Redirected(location string, status int) Decision {
+	return Decision{Action: ActionRedirect, Location: location, Status: status}
+}
+
+// Rewritten returns a `Decision` which serves the "name" file instead.
+func Rewritten(name string) Decision {
+	return Decision{Action: ActionRewrite, Name: name}
+}
+
+// decide calls the `Options.Authorize` for the "name".
+// It writes the response of a deny or a redirect decision and
+// returns the file name of a rewrite. It reports whether the request was handled.
+func (s *fileServer) decide(w http.ResponseWriter, r *http.Request, entry *AccessLogEntry, name string) (string, bool) {
+	if s.options.Authorize == nil {
+		return "", false
+	}
+
+	d := s.options.Authorize(r, name)
+	switch d.Action {
+	case ActionDeny:
+		status := d.Status
+		if status == 0 {
+			status = http.StatusForbidden
+		}
+
+		entry.Reason = d.Reason
+		s.writeError(w, r, status)
+		return "", true
+	case ActionRedirect:
+		status := d.Status
+		if status == 0 {
+			status = http.StatusFound
+		}
+
+		w.Header().Set("Location", d.Location)
+		w.WriteHeader(status)
+		return "", true
+	case ActionRewrite:
+		if d.Name == "" {
+			return "", false
+		}
+
+		return path.Clean(prefix(d.Name, "/")), false
+	default:
+		return "", false
+	}
+}
+
+// authorized reports whether the `Options.Authorize` allows the "name",
+// used to omit the `DirList` entries. Redirects and rewrites are allowed.
+func (s *fileServer) authorized(r *http.Request, name string) bool {
+	if s.options.Authorize == nil {
+		return true
+	}
+
+	return s.options.Authorize(r, name).Action != ActionDeny
+}
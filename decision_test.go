@@ -0,0 +1,83 @@
+package httpfs
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"public.txt":       "public",
+		"secret.txt":       "secret",
+		"other.txt":        "other",
+		"old/a.txt":        "old",
+		"docs/index.html":  "docs",
+		"list/visible.txt": "visible",
+		"list/secret.txt":  "secret",
+	})
+
+	var reasons []string
+	opts := DefaultOptions
+	opts.ShowList = true
+	opts.IndexNames = []string{"/index.html"}
+	opts.Authorize = func(r *http.Request, name string) Decision {
+		if name != cleanPath(name) {
+			t.Errorf("expected a cleaned name but got %q", name)
+		}
+
+		switch {
+		case strings.HasSuffix(name, "secret.txt"):
+			return Denied(http.StatusNotFound, "secret")
+		case strings.HasPrefix(name, "/old/"):
+			return Redirected("/new/", http.StatusMovedPermanently)
+		case name == "/rewrite":
+			return Rewritten("other.txt")
+		case name == "/docs/index.html":
+			return Denied(0, "index")
+		default:
+			return Allowed()
+		}
+	}
+	opts.AccessLog = func(entry *AccessLogEntry) { reasons = append(reasons, entry.Reason) }
+	h := FileServer(http.Dir(root), opts)
+
+	tests := []struct {
+		target   string
+		status   int
+		location string
+		body     string
+	}{
+		{"/public.txt", http.StatusOK, "", "public"},
+		{"/secret.txt", http.StatusNotFound, "", ""},
+		{"/old/a.txt", http.StatusMovedPermanently, "/new/", ""},
+		{"/x/../old/a.txt", http.StatusMovedPermanently, "/new/", ""},
+		{"/rewrite", http.StatusOK, "", "other"},
+		// the served index file is authorized too.
+		{"/docs/", http.StatusForbidden, "", ""},
+	}
+
+	for _, tt := range tests {
+		rec := serveTest(h, http.MethodGet, tt.target)
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d but got %d", tt.target, tt.status, rec.Code)
+		}
+
+		if got := rec.Header().Get("Location"); got != tt.location {
+			t.Errorf("%s: expected location %q but got %q", tt.target, tt.location, got)
+		}
+
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s: expected body %q but got %q", tt.target, tt.body, rec.Body.String())
+		}
+	}
+
+	if expected := []string{"", "secret", "", "", "", "index"}; strings.Join(reasons, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected reasons %q but got %q", expected, reasons)
+	}
+
+	body := serveTest(h, http.MethodGet, "/list/").Body.String()
+	if strings.Contains(body, "secret.txt") || !strings.Contains(body, "visible.txt") {
+		t.Fatalf("expected the denied files to be omitted from the listing but got:\n%s", body)
+	}
+}
@@ -0,0 +1,352 @@
+package httpfs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The deploy files, see `Options.DeployFiles`.
+const (
+	headersFile   = "/_headers"
+	redirectsFile = "/_redirects"
+)
+
+// deployConfig holds the parsed `_headers` and `_redirects` files.
+type deployConfig struct {
+	version   uint64 // the cache version these files were read from.
+	headers   []headersBlock
+	redirects []redirectRule
+}
+
+type (
+	// headersBlock is a path pattern of a `_headers` file
+	// followed by its indented "Key: Value" lines.
+	headersBlock struct {
+		pattern pathPattern
+		headers [][2]string
+	}
+
+	// redirectRule is a line of a `_redirects` file, e.g.
+	// /news/:year/*  /blog/:year/:splat  301!
+	redirectRule struct {
+		from   pathPattern
+		to     string
+		status int
+		force  bool
+	}
+)
+
+// pathPattern is a `_headers` and `_redirects` path which may contain
+// ":placeholder" segments and a trailing "*" splat, e.g. "/blog/:year/*".
+type pathPattern []string
+
+func parsePathPattern(s string) pathPattern {
+	return strings.Split(strings.Trim(s, "/"), "/")
+}
+
+// match reports whether the "name" matches the pattern
+// and returns the values of its placeholders and splat.
+func (p pathPattern) match(name string) (map[string]string, bool) {
+	segments := strings.Split(strings.Trim(name, "/"), "/")
+
+	var params map[string]string
+	for i, segment := range p {
+		if segment == "*" && i == len(p)-1 {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			if i < len(segments) {
+				params["splat"] = strings.Join(segments[i:], "/")
+			}
+			return params, true
+		}
+
+		if i >= len(segments) {
+			return nil, false
+		}
+
+		if len(segment) > 1 && segment[0] == ':' {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[segment[1:]] = segments[i]
+			continue
+		}
+
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, len(p) == len(segments)
+}
+
+// expand replaces the ":placeholder" and ":splat" parts of the "to" target.
+func expand(to string, params map[string]string) string {
+	if len(params) == 0 || !strings.Contains(to, ":") {
+		return to
+	}
+
+	segments := strings.Split(to, "/")
+	for i, segment := range segments {
+		if len(segment) > 1 && segment[0] == ':' {
+			if value, ok := params[segment[1:]]; ok {
+				segments[i] = value
+			}
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// parseHeaders parses the contents of a `_headers` file:
+// a path pattern at the start of a line followed by
+// indented "Key: Value" lines. Lines starting with # are comments.
+func parseHeaders(contents string) []headersBlock {
+	var blocks []headersBlock
+
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+
+		if line[0] != ' ' && line[0] != '\t' {
+			blocks = append(blocks, headersBlock{pattern: parsePathPattern(trimmed)})
+			continue
+		}
+
+		if len(blocks) == 0 {
+			continue // header without a path.
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+
+		block := &blocks[len(blocks)-1]
+		block.headers = append(block.headers, [2]string{strings.TrimSpace(key), strings.TrimSpace(value)})
+	}
+
+	return blocks
+}
+
+// parseRedirects parses the contents of a `_redirects` file,
+// one "from to [status][!]" rule per line. Lines starting with # are comments.
+// Query and condition ("Key=value") fields are ignored.
+// Lines of a status other than 200, 404 and 3xx are skipped and returned as errors.
+func parseRedirects(contents string) ([]redirectRule, []error) {
+	var (
+		rules []redirectRule
+		errs  []error
+		line  int
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0][0] == '#' {
+			continue
+		}
+
+		rule := redirectRule{from: parsePathPattern(fields[0]), status: http.StatusMovedPermanently}
+
+		rest := fields[1:]
+		for len(rest) > 0 && strings.Contains(rest[0], "=") {
+			rest = rest[1:]
+		}
+
+		if len(rest) == 0 {
+			continue // missing target.
+		}
+
+		rule.to, rest = rest[0], rest[1:]
+
+		if len(rest) > 0 && !strings.Contains(rest[0], "=") {
+			status := rest[0]
+			if strings.HasSuffix(status, "!") {
+				rule.force = true
+				status = status[:len(status)-1]
+			}
+
+			code, err := strconv.Atoi(status)
+			if err != nil || !validRedirectStatus(code) {
+				errs = append(errs, fmt.Errorf("line %d: invalid status %q", line, rest[0]))
+				continue
+			}
+
+			rule.status = code
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, errs
+}
+
+// validRedirectStatus reports whether a `_redirects` rule can be served
+// with the "code": a 200 rewrite, a 404 page or a 3xx redirect.
+func validRedirectStatus(code int) bool {
+	return code == http.StatusOK || code == http.StatusNotFound || (code >= 300 && code < 400)
+}
+
+// deployConfig returns the parsed deploy files of the served file system,
+// it reads them again when a `Cache` file system was rebuilt.
+// It returns nil when the `Options.DeployFiles` is false.
+func (s *fileServer) deployConfig(r *http.Request) *deployConfig {
+	if !s.options.DeployFiles {
+		return nil
+	}
+
+	var version uint64
+	if v, ok := s.fs.(versionedFS); ok {
+		version = v.cacheVersion()
+	}
+
+	if cfg := s.deploy.Load(); cfg != nil && cfg.version == version {
+		return cfg
+	}
+
+	s.deployMu.Lock()
+	defer s.deployMu.Unlock()
+
+	if cfg := s.deploy.Load(); cfg != nil && cfg.version == version {
+		return cfg
+	}
+
+	cfg := &deployConfig{version: version}
+	if contents, err := readFile(s.fs, headersFile); err == nil {
+		cfg.headers = parseHeaders(contents)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		s.reportError(r, headersFile, StageDeployFiles, err)
+	}
+
+	if contents, err := readFile(s.fs, redirectsFile); err == nil {
+		var errs []error
+		cfg.redirects, errs = parseRedirects(contents)
+		for _, err := range errs {
+			s.reportError(r, redirectsFile, StageDeployFiles, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		s.reportError(r, redirectsFile, StageDeployFiles, err)
+	}
+
+	s.deploy.Store(cfg)
+	return cfg
+}
+
+func readFile(fileSystem http.FileSystem, name string) (string, error) {
+	f, err := fileSystem.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	contents, err := io.ReadAll(f)
+	return string(contents), err
+}
+
+// writeHeaders sets the headers of all the `_headers` blocks
+// that match the "requestPath", later blocks override the previous ones.
+func (cfg *deployConfig) writeHeaders(w http.ResponseWriter, requestPath string) {
+	if cfg == nil {
+		return
+	}
+
+	h := w.Header()
+	for _, block := range cfg.headers {
+		if _, ok := block.pattern.match(requestPath); ok {
+			for _, kv := range block.headers {
+				h.Set(kv[0], kv[1])
+			}
+		}
+	}
+}
+
+// serveRedirects applies the first `_redirects` rule which matches the "name".
+// The "requestPath" is the original request path.
+// Rules which are not forced (no "!" suffix) apply only when the file does not "exist".
+// It returns the target of a 200 (rewrite) rule, which should be served instead,
+// and reports whether the request was handled (redirected or a 404 rule was served).
+func (s *fileServer) serveRedirects(w http.ResponseWriter, r *http.Request, cfg *deployConfig, requestPath, name string, exists bool) (string, bool) {
+	if cfg == nil {
+		return "", false
+	}
+
+	for _, rule := range cfg.redirects {
+		if exists && !rule.force {
+			continue
+		}
+
+		params, ok := rule.from.match(name)
+		if !ok {
+			continue
+		}
+
+		target := expand(rule.to, params)
+		isURL := strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+
+		switch rule.status {
+		case http.StatusOK:
+			if isURL {
+				continue // proxying is not supported.
+			}
+
+			return prefix(target, "/"), false
+		case http.StatusNotFound:
+			if err := s.serveErrorPage(w, r, http.StatusNotFound, target); err != nil {
+				s.reportError(r, target, StageErrorPage, err)
+				s.writeError(w, r, http.StatusNotFound)
+			}
+
+			return "", true
+		default:
+			if !isURL {
+				target = requestPrefix(r, requestPath) + prefix(target, "/")
+			}
+
+			if q := r.URL.RawQuery; q != "" && !strings.Contains(target, "?") {
+				target += "?" + q
+			}
+
+			w.Header().Set("Location", target)
+			w.WriteHeader(rule.status)
+			return "", true
+		}
+	}
+
+	return "", false
+}
+
+// requestPrefix returns the part of the request path before the "requestPath",
+// e.g. "/public" when the `FileServer` is registered through a http.StripPrefix("/public", ...).
+// The request URI is decoded and cleaned like the "requestPath",
+// so the result does not depend on how the client encoded it.
+func requestPrefix(r *http.Request, requestPath string) string {
+	u, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		return ""
+	}
+
+	uri := cleanPath(u.Path)
+	if requestPath == "/" {
+		return strings.TrimSuffix(uri, "/")
+	}
+
+	if !strings.HasSuffix(uri, requestPath) {
+		return "" // not served through a prefix.
+	}
+
+	return strings.TrimSuffix(uri[:len(uri)-len(requestPath)], "/")
+}
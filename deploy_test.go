@@ -0,0 +1,108 @@
+package httpfs
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseRedirects(t *testing.T) {
+	rules, errs := parseRedirects(`
+# comment
+/news/:year/*  /blog/:year/:splat
+/app/*         /app/index.html     200
+/old           /new                302!
+/geo           /us                 Country=us
+/zero          /new                0
+/gone          /new                410
+/bad           /new                abc
+/missing
+`)
+
+	if len(rules) != 4 {
+		t.Fatalf("expected 4 rules but got %d", len(rules))
+	}
+
+	expected := []struct {
+		status int
+		force  bool
+	}{{301, false}, {200, false}, {302, true}, {301, false}}
+	for i, rule := range rules {
+		if rule.status != expected[i].status || rule.force != expected[i].force {
+			t.Errorf("rule %d: expected status %d (force: %v) but got %d (force: %v)",
+				i, expected[i].status, expected[i].force, rule.status, rule.force)
+		}
+	}
+
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors but got %v", errs)
+	}
+}
+
+func TestDeployFiles(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"_headers":        "/assets/*\n  Cache-Control: no-store\n",
+		"_redirects":      "/news/:year/*  /blog/:year/:splat\n/app/*  /app/index.html  200\n/zero  /new  0\n/old  /new  302!\n",
+		"old":             "old",
+		"app/index.html":  "app",
+		"assets/main.css": "body{}",
+	})
+
+	var reported []string
+	opts := DefaultOptions
+	opts.DeployFiles = true
+	opts.OnError = func(r *http.Request, name string, stage ErrorStage, err error) {
+		if stage == StageDeployFiles {
+			reported = append(reported, err.Error())
+		}
+	}
+	h := FileServer(http.Dir(root), opts)
+
+	tests := []struct {
+		target   string
+		status   int
+		location string
+		body     string
+	}{
+		{"/news/2024/a/b?x=1", http.StatusMovedPermanently, "/blog/2024/a/b?x=1", ""},
+		{"/old", http.StatusFound, "/new", ""},
+		{"/%6fld", http.StatusFound, "/new", ""},
+		{"/x/../old", http.StatusFound, "/new", ""},
+		{"/app/users/1", http.StatusOK, "", "app"},
+		{"/zero", http.StatusNotFound, "", ""},
+		{"/_redirects", http.StatusNotFound, "", ""},
+		{"/_headers", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		rec := serveTest(h, http.MethodGet, tt.target)
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d but got %d", tt.target, tt.status, rec.Code)
+		}
+
+		if got := rec.Header().Get("Location"); got != tt.location {
+			t.Errorf("%s: expected location %q but got %q", tt.target, tt.location, got)
+		}
+
+		if tt.body != "" && strings.TrimSpace(rec.Body.String()) != tt.body {
+			t.Errorf("%s: expected body %q but got %q", tt.target, tt.body, rec.Body.String())
+		}
+	}
+
+	// the redirects of a prefixed file server keep the prefix.
+	prefixed := http.StripPrefix("/public", h)
+	for _, target := range []string{"/public/old", "/public/%6fld", "/public/x/../old"} {
+		rec := serveTest(prefixed, http.MethodGet, target)
+		if got := rec.Header().Get("Location"); got != "/public/new" {
+			t.Errorf("%s: expected location %q but got %q", target, "/public/new", got)
+		}
+	}
+
+	if rec := serveTest(h, http.MethodGet, "/assets/main.css"); rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("expected the _headers Cache-Control but got %q", rec.Header().Get("Cache-Control"))
+	}
+
+	if len(reported) != 1 {
+		t.Fatalf("expected the invalid rule to be reported once but got %v", reported)
+	}
+}
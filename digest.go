@@ -0,0 +1,170 @@
+package httpfs
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PushPolicy describes which of the push targets of an index file
+// are pushed to a client. See `Options.PushPolicy`.
+type PushPolicy uint8
+
+const (
+	// PushAlways pushes all the targets on each request.
+	// This is the zero value.
+	PushAlways PushPolicy = iota
+	// PushOncePerVersion pushes a target only if the client was not pushed
+	// the same contents version of it before. The pushed targets are tracked
+	// through a compact digest cookie, see `Options.PushCookie`.
+	// The versions are read once and again on each `Rebuild` of a `Cache` file system,
+	// set the `Options.PushTargetsLive` to notice the changed files of other file systems.
+	PushOncePerVersion
+	// PushNever disables pushing, e.g. to keep only the `Options.Preload` links.
+	PushNever
+)
+
+// DefaultPushCookie is the default `Options.PushCookie` name.
+const DefaultPushCookie = "_httpfs_push"
+
+// maxPushDigest is the maximum number of pushed targets tracked per client,
+// the older ones are dropped first, so the cookie stays under 1.5KB.
+const maxPushDigest = 256
+
+// filterPushed returns the "targets" that should be pushed to the client,
+// based on the `Options.PushPolicy`, and a function which records
+// the first "n" of them as pushed through the digest cookie.
+// It should be called after the pushes, before the response headers are written.
+// The "requestPath" is the original request path.
+func (s *fileServer) filterPushed(r *http.Request, requestPath string, targets []string) ([]string, func(w http.ResponseWriter, n int)) {
+	switch s.options.PushPolicy {
+	case PushNever:
+		return nil, nil
+	case PushOncePerVersion:
+	default:
+		return targets, nil
+	}
+
+	cookieName := s.options.PushCookie
+	if cookieName == "" {
+		cookieName = DefaultPushCookie
+	}
+
+	var digest []uint32
+	if c, err := r.Cookie(cookieName); err == nil {
+		digest = decodePushDigest(c.Value)
+	}
+
+	mountPrefix := requestPrefix(r, requestPath)
+
+	var (
+		filtered = targets[:0:0]
+		hashes   []uint32 // of the filtered targets.
+	)
+
+	for _, target := range targets {
+		h := s.targetDigest(target, mountPrefix)
+		if containsDigest(digest, h) || containsDigest(hashes, h) {
+			continue // already pushed, probably in the browser's cache.
+		}
+
+		hashes = append(hashes, h)
+		filtered = append(filtered, target)
+	}
+
+	pushed := func(w http.ResponseWriter, n int) {
+		if n <= 0 {
+			return
+		}
+
+		digest = append(digest, hashes[:n]...)
+		if len(digest) > maxPushDigest {
+			digest = digest[len(digest)-maxPushDigest:]
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookieName,
+			Value:    encodePushDigest(digest),
+			Path:     mountPrefix + "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	return filtered, pushed
+}
+
+// targetDigest returns the hash of the "target" file name and its
+// contents version (modification time and size), the "mountPrefix" is trimmed.
+// The hashes are kept until a `Cache` file system is rebuilt,
+// unless the `Options.PushTargetsLive` is true.
+func (s *fileServer) targetDigest(target, mountPrefix string) uint32 {
+	name := target
+	if idx := strings.IndexByte(name, '?'); idx != -1 {
+		name = name[:idx]
+	}
+	name = strings.TrimPrefix(name, mountPrefix)
+
+	var set *pushSet
+	if !s.options.PushTargetsLive {
+		set = s.pushSet()
+		if h, ok := set.digests.Load(name); ok {
+			return h.(uint32)
+		}
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(name))
+
+	if info, err := stat(s.fs, name); err == nil {
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.FormatInt(info.ModTime().UnixNano(), 36)))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.FormatInt(info.Size(), 36)))
+	}
+
+	sum := h.Sum32()
+	if set != nil {
+		set.digests.Store(name, sum)
+	}
+
+	return sum
+}
+
+func containsDigest(digest []uint32, h uint32) bool {
+	for _, d := range digest {
+		if d == h {
+			return true
+		}
+	}
+
+	return false
+}
+
+func encodePushDigest(digest []uint32) string {
+	b := make([]byte, 4*len(digest))
+	for i, h := range digest {
+		binary.BigEndian.PutUint32(b[4*i:], h)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodePushDigest decodes a digest cookie value,
+// an invalid one results to an empty digest.
+func decodePushDigest(value string) []uint32 {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(b)%4 != 0 {
+		return nil
+	}
+
+	digest := make([]uint32, 0, len(b)/4)
+	for i := 0; i < len(b); i += 4 {
+		digest = append(digest, binary.BigEndian.Uint32(b[i:]))
+	}
+
+	return digest
+}
@@ -0,0 +1,162 @@
+package httpfs
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// failingPusher fails to push after "ok" pushes.
+type failingPusher struct {
+	*httptest.ResponseRecorder
+	ok     int
+	pushed []string
+}
+
+func (w *failingPusher) Push(target string, opts *http.PushOptions) error {
+	if len(w.pushed) >= w.ok {
+		return errors.New("stream closed")
+	}
+
+	w.pushed = append(w.pushed, target)
+	return nil
+}
+
+// countingFS counts the opened files.
+type countingFS struct {
+	http.FileSystem
+	opened int32
+}
+
+func (fs *countingFS) Open(name string) (http.File, error) {
+	atomic.AddInt32(&fs.opened, 1)
+	return fs.FileSystem.Open(name)
+}
+
+func TestPushOncePerVersion(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"index.html": "index",
+		"a.js":       "a",
+		"b.css":      "b",
+		"c.png":      "c",
+	})
+
+	fileSystem := &countingFS{FileSystem: http.Dir(root)}
+
+	opts := DefaultOptions
+	opts.PushTargets = map[string][]string{"/": {"/a.js", "/b.css", "/c.png"}}
+	opts.PushPolicy = PushOncePerVersion
+	h := FileServer(fileSystem, opts)
+
+	serve := func(ok int, cookies ...*http.Cookie) *failingPusher {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+
+		w := &failingPusher{ResponseRecorder: httptest.NewRecorder(), ok: ok}
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// the push fails after the first target.
+	w := serve(1)
+	if len(w.pushed) != 1 {
+		t.Fatalf("expected 1 pushed target but got %v", w.pushed)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || len(decodePushDigest(cookies[0].Value)) != 1 {
+		t.Fatalf("expected a digest of the pushed target only but got %v", cookies)
+	}
+
+	// the targets that failed are pushed on the next request.
+	w = serve(3, cookies...)
+	if len(w.pushed) != 2 || w.pushed[0] != "/b.css" || w.pushed[1] != "/c.png" {
+		t.Fatalf("expected the rest of the targets to be pushed but got %v", w.pushed)
+	}
+
+	cookies = w.Result().Cookies()
+	if len(cookies) != 1 || len(decodePushDigest(cookies[0].Value)) != 3 {
+		t.Fatalf("expected a digest of all the targets but got %v", cookies)
+	}
+
+	opened := atomic.LoadInt32(&fileSystem.opened)
+	w = serve(3, cookies...)
+	if len(w.pushed) != 0 || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("expected no pushes and no cookie but got %v, %v", w.pushed, w.Result().Cookies())
+	}
+
+	// the index file and its directory only, the target digests are kept.
+	if n := atomic.LoadInt32(&fileSystem.opened) - opened; n > 2 {
+		t.Fatalf("expected the targets to not be opened again but %d files were opened", n)
+	}
+}
+
+func TestPushOncePerVersionKeys(t *testing.T) {
+	root := testDir(t, map[string]string{"index.html": "index", "main.js": "js"})
+
+	opts := DefaultOptions
+	opts.SPA = true
+	opts.PushTargets = map[string][]string{"/index.html": {"main.js"}}
+	opts.PushPolicy = PushOncePerVersion
+	s := FileServer(http.Dir(root), opts).(*fileServer)
+
+	for _, target := range []string{"/app/users", "/app/user%73", "/%61pp/users"} {
+		w := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		cookies := w.Result().Cookies()
+		if len(w.pushed) != 1 || w.pushed[0] != "/main.js" || len(cookies) != 1 || cookies[0].Path != "/" {
+			t.Fatalf("%s: expected /main.js to be pushed with a root cookie but got %v, %v", target, w.pushed, cookies)
+		}
+	}
+
+	var keys []string
+	s.pushSet().digests.Range(func(key, _ interface{}) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+
+	if len(keys) != 1 || keys[0] != "/main.js" {
+		t.Fatalf("expected the digests to be kept by file name but got %q", keys)
+	}
+}
+
+func TestPushOncePerVersionLive(t *testing.T) {
+	root := testDir(t, map[string]string{"index.html": "index", "main.js": "js"})
+
+	opts := DefaultOptions
+	opts.PushTargets = map[string][]string{"/": {"/main.js"}}
+	opts.PushPolicy = PushOncePerVersion
+	opts.PushTargetsLive = true
+	h := FileServer(http.Dir(root), opts)
+
+	serve := func(cookies []*http.Cookie) *pushRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+
+		w := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	cookies := serve(nil).Result().Cookies()
+	if w := serve(cookies); len(w.pushed) != 0 {
+		t.Fatalf("expected no pushes but got %v", w.pushed)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "main.js"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serve(cookies); len(w.pushed) != 1 {
+		t.Fatalf("expected the changed file to be pushed again but got %v", w.pushed)
+	}
+}
@@ -0,0 +1,83 @@
+package httpfs
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sanitizeFilename removes the control characters and the path separators
+// of an attachment "name", e.g. the result of an `Attachments.NameFunc`,
+// so it cannot inject headers or point to another directory.
+func sanitizeFilename(name string) string {
+	name = strings.ToValidUTF8(name, "_")
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '_'
+		case unicode.IsControl(r):
+			return -1
+		default:
+			return r
+		}
+	}, name)
+
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "download"
+	}
+
+	return name
+}
+
+// contentDisposition returns a RFC 6266 Content-Disposition header value
+// of the "dispositionType" (e.g. "attachment") and the file "name":
+// a quoted ASCII "filename" fallback, non-ASCII characters replaced by "_",
+// followed by the RFC 5987 encoded "filename*" one, e.g.
+//
+//	attachment; filename="_.txt"; filename*=UTF-8''%CE%B1.txt
+func contentDisposition(dispositionType, name string) string {
+	name = sanitizeFilename(name) // no backslashes left to escape.
+
+	var ascii strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= utf8.RuneSelf:
+			ascii.WriteByte('_')
+		case r == '"':
+			ascii.WriteString(`\"`)
+		default:
+			ascii.WriteRune(r)
+		}
+	}
+
+	return dispositionType + `; filename="` + ascii.String() + `"; filename*=UTF-8''` + encodeRFC5987(name)
+}
+
+// encodeRFC5987 percent-encodes the "s" bytes which are not a RFC 5987 attr-char.
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0F])
+	}
+
+	return b.String()
+}
+
+func isAttrChar(c byte) bool {
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return true
+	}
+
+	return strings.IndexByte("!#$&+-.^_`|~", c) != -1
+}
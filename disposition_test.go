@@ -0,0 +1,26 @@
+package httpfs
+
+import "testing"
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"report.pdf", `attachment; filename="report.pdf"; filename*=UTF-8''report.pdf`},
+		{"my report; v2.pdf", `attachment; filename="my report; v2.pdf"; filename*=UTF-8''my%20report%3B%20v2.pdf`},
+		{`a "quoted" name.txt`, `attachment; filename="a \"quoted\" name.txt"; filename*=UTF-8''a%20%22quoted%22%20name.txt`},
+		{"αβ.txt", `attachment; filename="__.txt"; filename*=UTF-8''%CE%B1%CE%B2.txt`},
+		{"../../etc/passwd", `attachment; filename=".._.._etc_passwd"; filename*=UTF-8''.._.._etc_passwd`},
+		{`a\b.txt`, `attachment; filename="a_b.txt"; filename*=UTF-8''a_b.txt`},
+		{"a\r\nSet-Cookie: x=1.txt", `attachment; filename="aSet-Cookie: x=1.txt"; filename*=UTF-8''aSet-Cookie%3A%20x%3D1.txt`},
+		{"..", `attachment; filename="download"; filename*=UTF-8''download`},
+		{"", `attachment; filename="download"; filename*=UTF-8''download`},
+	}
+
+	for _, tt := range tests {
+		if got := contentDisposition("attachment", tt.name); got != tt.expected {
+			t.Errorf("%q: expected\n%s\nbut got\n%s", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package httpfs
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+)
+
+// errIsDirectory is returned when an `Options.ErrorPages` entry points to a directory.
+var errIsDirectory = errors.New("is a directory")
+
+// writeError sends the "status" error code to the client.
+// It fires the `Options.ErrorHandlers` for that status code, if any,
+// otherwise it serves its `Options.ErrorPages` file, if any,
+// otherwise it writes just the status code.
+func (s *fileServer) writeError(w http.ResponseWriter, r *http.Request, status int) {
+	if h := s.options.ErrorHandlers[status]; h != nil {
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	if name := s.options.ErrorPages[status]; name != "" {
+		err := s.serveErrorPage(w, r, status, name)
+		if err == nil {
+			return
+		}
+
+		s.reportError(r, name, StageErrorPage, err)
+	}
+
+	w.WriteHeader(status)
+}
+
+// serveErrorPage writes the contents of the "name" file of the served file system
+// with the given "status" code. It respects the cached encoding and
+// compresses the page on the fly when `Options.Compress` is true.
+// It returns a non-nil error when the page could not be opened,
+// the caller should then fallback to a plain status code.
+func (s *fileServer) serveErrorPage(w http.ResponseWriter, r *http.Request, status int, name string) error {
+	name = prefix(name, "/")
+
+	f, err := s.open(name, r)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return errIsDirectory
+	}
+
+	h := w.Header()
+	// The error response is not the requested resource,
+	// drop any representation metadata of it.
+	h.Del("Content-Disposition")
+	h.Del("ETag")
+	h.Del("Last-Modified")
+
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if ctype == "" {
+		var buf [512]byte
+		n, _ := io.ReadFull(f, buf[:])
+		ctype = http.DetectContentType(buf[:n])
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	writeContentType(w, ctype)
+
+	encoding, isCached := GetEncoding(f)
+	if isCached {
+		if encoding != "" {
+			addCompressHeaders(h, encoding)
+		}
+	} else if s.options.Compress {
+		var done func()
+		w, encoding, done = s.compressResponse(w, r, name)
+		defer done()
+	}
+
+	// cached files do not report their size.
+	if !isCached && encoding == "" {
+		h.Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	}
+
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		// The status code is already sent, an error here
+		// cannot fallback to the plain status code.
+		if _, err = io.Copy(w, f); err != nil {
+			s.reportError(r, name, StageErrorPage, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package httpfs
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestErrorPages(t *testing.T) {
+	page := "<html>" + strings.Repeat("not found ", 100) + "</html>"
+	root := testDir(t, map[string]string{
+		"404.html":      page,
+		"403.html":      "forbidden",
+		"private/a.txt": "a",
+	})
+
+	opts := DefaultOptions
+	opts.Compress = false
+	opts.Deny = []string{"/private/*"}
+	opts.ErrorHandlers = map[int]http.Handler{
+		http.StatusForbidden: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, "handler")
+		}),
+	}
+	opts.ErrorPages = map[int]string{
+		http.StatusNotFound:  "404.html",
+		http.StatusForbidden: "/403.html",
+	}
+	h := FileServer(http.Dir(root), opts)
+
+	// the handler takes precedence over the page.
+	rec := serveTest(h, http.MethodGet, "/private/a.txt")
+	if rec.Code != http.StatusForbidden || rec.Body.String() != "handler" {
+		t.Errorf("expected the 403 handler but got %d: %q", rec.Code, rec.Body.String())
+	}
+
+	rec = serveTest(h, http.MethodGet, "/missing.txt")
+	if rec.Code != http.StatusNotFound || rec.Body.String() != page {
+		t.Fatalf("expected the 404 page but got %d: %q", rec.Code, rec.Body.String())
+	}
+
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("expected an html Content-Type but got %q", got)
+	}
+
+	if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(page)) {
+		t.Errorf("expected Content-Length %d but got %q", len(page), got)
+	}
+
+	rec = serveTest(h, http.MethodHead, "/missing.txt")
+	if rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+		t.Errorf("HEAD: expected 404 without a body but got %d: %q", rec.Code, rec.Body.String())
+	}
+
+	if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(page)) {
+		t.Errorf("HEAD: expected Content-Length %d but got %q", len(page), got)
+	}
+}
+
+func TestErrorPagesEncoding(t *testing.T) {
+	page := "<html>" + strings.Repeat("not found ", 100) + "</html>"
+	root := testDir(t, map[string]string{"404.html": page})
+
+	opts := DefaultOptions
+	opts.ErrorPages = map[int]string{http.StatusNotFound: "/404.html"}
+
+	tests := []struct {
+		name string
+		fs   http.FileSystem
+	}{
+		{"compressed", http.Dir(root)},
+		{"cached", MustCache(http.Dir(root), DefaultCacheOptions)},
+	}
+
+	for _, tt := range tests {
+		h := FileServer(tt.fs, opts)
+
+		rec := serveTest(h, http.MethodGet, "/missing.txt", "Accept-Encoding", "gzip")
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected status 404 but got %d", tt.name, rec.Code)
+		}
+
+		if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("%s: expected Content-Encoding gzip but got %q", tt.name, got)
+		}
+
+		gr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if body, err := io.ReadAll(gr); err != nil || string(body) != page {
+			t.Errorf("%s: expected the decompressed page but got %q (%v)", tt.name, body, err)
+		}
+	}
+}
+
+func TestErrorPagesFallback(t *testing.T) {
+	root := testDir(t, map[string]string{"errors/a.txt": "a"})
+
+	var stages []ErrorStage
+	opts := DefaultOptions
+	opts.OnError = func(r *http.Request, name string, stage ErrorStage, err error) {
+		if stage == StageErrorPage {
+			stages = append(stages, stage)
+			if name == "/errors" && !errors.Is(err, errIsDirectory) {
+				t.Errorf("expected the directory error but got %v", err)
+			}
+		}
+	}
+
+	for _, page := range []string{"/missing.html", "/errors"} {
+		stages = nil
+		opts.ErrorPages = map[int]string{http.StatusNotFound: page}
+		h := FileServer(http.Dir(root), opts)
+
+		rec := serveTest(h, http.MethodGet, "/missing.txt")
+		if rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+			t.Errorf("%s: expected a plain 404 but got %d: %q", page, rec.Code, rec.Body.String())
+		}
+
+		if len(stages) != 1 {
+			t.Errorf("%s: expected the page failure to be reported once but got %d", page, len(stages))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package httpfs
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"github.com/kataras/compress"
+)
+
+// ErrorStage describes the step of serving a request
+// that a `FileServer` failure happened on. See `Options.OnError`.
+type ErrorStage uint8
+
+// The available error stages.
+const (
+	// StageOpen is the stage of opening the requested file
+	// or the SPA index file.
+	StageOpen ErrorStage = iota + 1
+	// StageStat is the stage of reading the information of an opened file.
+	StageStat
+	// StageIndex is the stage of resolving the `IndexName(s)` file of a directory.
+	StageIndex
+	// StageDirList is the stage of rendering a directory through `DirList`.
+	StageDirList
+	// StagePush is the stage of discovering and pushing the `PushTargets`
+	// and `PushTargetsRegexp` of an index file.
+	StagePush
+	// StageCompress is the stage of creating and closing
+	// the on-the-fly compression writer.
+	StageCompress
+	// StageErrorPage is the stage of rendering an `ErrorPages` file.
+	StageErrorPage
+	// StageDeployFiles is the stage of reading the `_headers` and `_redirects` files.
+	StageDeployFiles
+)
+
+var stageNames = map[ErrorStage]string{
+	StageOpen:        "open",
+	StageStat:        "stat",
+	StageIndex:       "index",
+	StageDirList:     "dirlist",
+	StagePush:        "push",
+	StageCompress:    "compress",
+	StageErrorPage:   "errorpage",
+	StageDeployFiles: "deployfiles",
+}
+
+// String returns the text representation of the stage, e.g. "open".
+func (s ErrorStage) String() string {
+	if name, ok := stageNames[s]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// reportError fires the `Options.OnError` callback, if any.
+func (s *fileServer) reportError(r *http.Request, name string, stage ErrorStage, err error) {
+	if err == nil || s.options.OnError == nil {
+		return
+	}
+
+	s.options.OnError(r, name, stage, err)
+}
+
+// errorStatus returns the status code which describes the "err" best:
+// 400 for invalid paths, 404 for missing files, 403 for permission errors
+// and 500 for anything else (e.g. I/O errors).
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidPath):
+		return http.StatusBadRequest
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// compressResponse wraps the "w" with a compress writer which
+// encodes the response based on the client's Accept-Encoding header.
+// It returns the original "w" and an empty encoding when the response
+// should not be compressed. The "done" function must be called
+// after the response is written to flush the compressed data.
+func (s *fileServer) compressResponse(w http.ResponseWriter, r *http.Request, name string) (http.ResponseWriter, string, func()) {
+	// the compress writer overrides the "Vary" header, keep the existing values, e.g. "Origin".
+	vary := w.Header().Values("Vary")
+	vary = append(vary[:0:0], vary...)
+
+	cr, err := compress.NewResponseWriter(w, r, -1)
+	if err == nil {
+		for _, v := range vary {
+			addVary(w.Header(), v)
+		}
+	} else {
+		// Missing or not supported Accept-Encoding is not a failure.
+		if !errors.Is(err, compress.ErrResponseNotCompressed) && !errors.Is(err, compress.ErrNotSupportedCompression) {
+			s.reportError(r, name, StageCompress, err)
+		}
+
+		return w, "", func() {}
+	}
+
+	return cr, cr.Encoding, func() {
+		s.reportError(r, name, StageCompress, cr.Close())
+	}
+}
+
+// addCompressHeaders is like the `compress.AddCompressHeaders`
+// but it adds the "Accept-Encoding" to the existing "Vary" header values.
+func addCompressHeaders(h http.Header, encoding string) {
+	addVary(h, compress.AcceptEncodingHeaderKey)
+	h.Set(compress.ContentEncodingHeaderKey, encoding)
+}
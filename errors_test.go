@@ -0,0 +1,125 @@
+package httpfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// errorFS fails to open the "open" names and to stat the "stat" ones.
+type errorFS struct {
+	http.FileSystem
+	open map[string]error
+	stat map[string]error
+}
+
+func (fs *errorFS) Open(name string) (http.File, error) {
+	if err := fs.open[name]; err != nil {
+		return nil, err
+	}
+
+	f, err := fs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fs.stat[name]; err != nil {
+		return &statErrorFile{File: f, err: err}, nil
+	}
+
+	return f, nil
+}
+
+type statErrorFile struct {
+	http.File
+	err error
+}
+
+func (f *statErrorFile) Stat() (fs.FileInfo, error) {
+	return nil, f.err
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{ErrInvalidPath, http.StatusBadRequest},
+		{fmt.Errorf("open: %w", fs.ErrNotExist), http.StatusNotFound},
+		{fmt.Errorf("open: %w", fs.ErrPermission), http.StatusForbidden},
+		{errors.New("input/output error"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := errorStatus(tt.err); got != tt.status {
+			t.Errorf("%v: expected status %d but got %d", tt.err, tt.status, got)
+		}
+	}
+}
+
+func TestOnError(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"denied.txt":      "denied",
+		"broken.txt":      "broken",
+		"stat.txt":        "stat",
+		"about.html":      "about",
+		"docs/index.html": "docs",
+		"list/a.txt":      "a",
+	})
+
+	ioErr := errors.New("input/output error")
+	fileSystem := &errorFS{
+		FileSystem: http.Dir(root),
+		open: map[string]error{
+			"/denied.txt":      fs.ErrPermission,
+			"/broken.txt":      ioErr,
+			"/about.html":      fs.ErrPermission,
+			"/docs/index.html": ioErr,
+		},
+		stat: map[string]error{"/stat.txt": ioErr},
+	}
+
+	var reported []string
+	opts := DefaultOptions
+	opts.ShowList = true
+	opts.Extensions = []string{".html"}
+	opts.OnError = func(r *http.Request, name string, stage ErrorStage, err error) {
+		reported = append(reported, stage.String()+" "+name)
+	}
+	opts.DirList = func(w http.ResponseWriter, r *http.Request, opts Options, name string, dir http.File) error {
+		if name == "list" {
+			return ioErr
+		}
+
+		return DirList(w, r, opts, name, dir)
+	}
+	h := FileServer(fileSystem, opts)
+
+	tests := []struct {
+		target   string
+		status   int
+		reported []string
+	}{
+		{"/denied.txt", http.StatusForbidden, []string{"open /denied.txt"}},
+		{"/broken.txt", http.StatusInternalServerError, []string{"open /broken.txt"}},
+		{"/stat.txt", http.StatusInternalServerError, []string{"stat /stat.txt"}},
+		{"/about", http.StatusNotFound, []string{"open /about.html", "open /about"}},
+		{"/docs/", http.StatusOK, []string{"index /docs/index.html"}},
+		{"/list/", http.StatusInternalServerError, []string{"dirlist /list/"}},
+	}
+
+	for _, tt := range tests {
+		reported = nil
+		rec := serveTest(h, http.MethodGet, tt.target)
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d but got %d", tt.target, tt.status, rec.Code)
+		}
+
+		if !reflect.DeepEqual(reported, tt.reported) {
+			t.Errorf("%s: expected the %v errors but got %v", tt.target, tt.reported, reported)
+		}
+	}
+}
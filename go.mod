@@ -4,6 +4,8 @@ go 1.19
 
 require (
 	github.com/kataras/compress v0.0.6
+	golang.org/x/crypto v0.14.0
+	golang.org/x/net v0.17.0
 	golang.org/x/time v0.3.0
 )
 
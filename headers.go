@@ -0,0 +1,160 @@
+package httpfs
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// HeaderRule maps a set of files to the response headers
+// they should be served with. See `Options.Headers`.
+type HeaderRule struct {
+	// Patterns holds the glob patterns (see `path.Match`) of the file names
+	// this rule applies to, matched like the `Options.Deny` ones,
+	// e.g. "*.js" or "/static/*". Empty matches all files.
+	Patterns []string
+	// MIMETypes holds the media types of the files this rule applies to,
+	// e.g. "text/html" or "image/*". The media type is resolved by the file extension.
+	// Empty matches all files.
+	MIMETypes []string
+	// Index, if true, applies this rule only to the `IndexName(s)` files
+	// of directories, the directory listings (see `ShowList`) and the `SPA` fallbacks.
+	Index bool
+	// Headers to set, e.g. "Cache-Control": "no-cache".
+	// An empty value removes the header set by a previous rule.
+	Headers map[string]string
+}
+
+// DefaultHeaders is a set of recommended `Options.Headers` rules.
+// Index files, directory listings and SPA fallbacks are always revalidated (no-cache)
+// and everything else is cached for a year.
+// It also disables the content type sniffing of the clients.
+//
+// The responses of authenticated (see `Options.Auth`) or signed (see `Options.SignedURLs`)
+// requests are cached as "private" instead.
+//
+// Use it on files with content-hashed names, e.g. "main.3fa2c1.js",
+// otherwise clients may use stale files for up to a year.
+var DefaultHeaders = []HeaderRule{
+	{
+		Headers: map[string]string{
+			"Cache-Control":          "public, max-age=31536000",
+			"X-Content-Type-Options": "nosniff",
+		},
+	},
+	{
+		Index: true,
+		Headers: map[string]string{
+			"Cache-Control": "no-cache",
+		},
+	},
+}
+
+func (rule *HeaderRule) match(name, ctype string, index bool) bool {
+	if rule.Index && !index {
+		return false
+	}
+
+	if !matchAny(rule.Patterns, name) {
+		return false
+	}
+
+	if len(rule.MIMETypes) > 0 {
+		return matchMIME(rule.MIMETypes, ctype)
+	}
+
+	return true
+}
+
+// matchMIME reports whether the "ctype" content type
+// matches any of the "mimeTypes", e.g. "text/*" matches "text/html; charset=utf-8".
+func matchMIME(mimeTypes []string, ctype string) bool {
+	if ctype == "" {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+
+	for _, m := range mimeTypes {
+		m = strings.ToLower(m)
+		if m == mediaType || m == "*/*" {
+			return true
+		}
+
+		if strings.HasSuffix(m, "/*") && strings.HasPrefix(mediaType, m[:len(m)-1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// privateCache makes a public "Cache-Control" header private,
+// e.g. "public, max-age=31536000, s-maxage=600" becomes "private, max-age=31536000",
+// so shared caches do not store the responses of authenticated or signed requests.
+func privateCache(h http.Header) {
+	value := h.Get("Cache-Control")
+	if value == "" {
+		return
+	}
+
+	directives := strings.Split(value, ",")
+	kept := directives[:0]
+	public := false
+	for _, directive := range directives {
+		directive = strings.TrimSpace(directive)
+		name, _, _ := strings.Cut(strings.ToLower(directive), "=")
+		switch name {
+		case "public":
+			public = true
+			continue
+		case "s-maxage":
+			continue
+		}
+
+		kept = append(kept, directive)
+	}
+
+	if !public {
+		return
+	}
+
+	h.Set("Cache-Control", strings.Join(append([]string{"private"}, kept...), ", "))
+}
+
+// writeHeaderRules sets the response headers of the matched `Options.Headers` rules
+// and the "_headers" file blocks (see `Options.DeployFiles`) that match the "requestPath".
+// The "name" is the served file name (e.g. "/index.html" on a directory request)
+// and "ctype" its content type, if known.
+func (s *fileServer) writeHeaderRules(w http.ResponseWriter, r *http.Request, requestPath, name, ctype string, index bool) {
+	s.deployConfig(r).writeHeaders(w, requestPath)
+
+	if len(s.options.Headers) == 0 {
+		return
+	}
+
+	if ctype == "" {
+		ctype = mime.TypeByExtension(path.Ext(name))
+	}
+
+	h := w.Header()
+	for i := range s.options.Headers {
+		rule := &s.options.Headers[i]
+		if !rule.match(name, ctype, index) {
+			continue
+		}
+
+		for key, value := range rule.Headers {
+			if value == "" {
+				h.Del(key)
+				continue
+			}
+
+			h.Set(key, value)
+		}
+	}
+}
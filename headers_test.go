@@ -0,0 +1,101 @@
+package httpfs
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDefaultHeaders(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"index.html":   "index",
+		"main.3fa2.js": "js",
+		"dir/a.txt":    "a",
+		"page.html":    "page",
+	})
+
+	opts := DefaultOptions
+	opts.ShowList = true
+	opts.Extensions = []string{".html"}
+	opts.Headers = append(DefaultHeaders, HeaderRule{
+		MIMETypes: []string{"text/html"},
+		Headers:   map[string]string{"Content-Security-Policy": "default-src 'self'"},
+	})
+	h := FileServer(http.Dir(root), opts)
+
+	tests := []struct {
+		target       string
+		cacheControl string
+		csp          bool
+	}{
+		{"/", "no-cache", true},
+		{"/page", "no-cache", true},
+		{"/main.3fa2.js", "public, max-age=31536000", false},
+		{"/dir/", "no-cache", true},
+		{"/dir/?format=json", "no-cache", false},
+	}
+
+	for _, tt := range tests {
+		rec := serveTest(h, http.MethodGet, tt.target)
+		if got := rec.Header().Get("Cache-Control"); got != tt.cacheControl {
+			t.Errorf("%s: expected Cache-Control %q but got %q", tt.target, tt.cacheControl, got)
+		}
+
+		if got := rec.Header().Get("Content-Security-Policy") != ""; got != tt.csp {
+			t.Errorf("%s: expected Content-Security-Policy: %v but got %v", tt.target, tt.csp, got)
+		}
+
+		if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("%s: expected X-Content-Type-Options nosniff but got %q", tt.target, got)
+		}
+	}
+}
+
+func TestDefaultHeadersPrivate(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"main.3fa2.js":        "js",
+		"private/main.js":     "private",
+		"private/dir/a.txt":   "a",
+		"signed/report.pdf":   "report",
+		"signed/shared.3f.js": "shared",
+	})
+
+	signer := &URLSigner{Keys: map[string][]byte{"k": []byte("secret")}, KeyID: "k", Patterns: []string{"/signed/*"}}
+
+	opts := DefaultOptions
+	opts.ShowList = true
+	opts.SignedURLs = signer
+	opts.Auth = Auth{
+		Authenticators: []Authenticator{BearerTokens("files", map[string]string{"token": "bob"})},
+		Rules:          []AuthRule{{Patterns: []string{"/private/*"}, Principals: []string{"bob"}}},
+	}
+	opts.Headers = append(DefaultHeaders, HeaderRule{
+		Patterns: []string{"*.js"},
+		Headers:  map[string]string{"Cache-Control": "public, max-age=600, s-maxage=60"},
+	})
+	h := FileServer(http.Dir(root), opts)
+
+	bearer := []string{"Authorization", "Bearer token"}
+	tests := []struct {
+		target       string
+		header       []string
+		cacheControl string
+	}{
+		{"/main.3fa2.js", nil, "public, max-age=600, s-maxage=60"},
+		{"/private/main.js", bearer, "private, max-age=600"},
+		{"/private/dir/", bearer, "no-cache"},
+		{signer.Sign("/signed/report.pdf", time.Now().Add(time.Hour), SignOptions{}), nil, "private, max-age=31536000"},
+		{signer.Sign("/signed/shared.3f.js", time.Now().Add(time.Hour), SignOptions{}), nil, "private, max-age=600"},
+	}
+
+	for _, tt := range tests {
+		rec := serveTest(h, http.MethodGet, tt.target, tt.header...)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200 but got %d", tt.target, rec.Code)
+		}
+
+		if got := rec.Header().Get("Cache-Control"); got != tt.cacheControl {
+			t.Errorf("%s: expected Cache-Control %q but got %q", tt.target, tt.cacheControl, got)
+		}
+	}
+}
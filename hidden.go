@@ -0,0 +1,168 @@
+package httpfs
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// DotFilesPolicy describes how the `FileServer` treats
+// path segments that start with a dot, e.g. "/.git/config" or "/.env".
+// See `Options.DotFiles`.
+type DotFilesPolicy uint8
+
+const (
+	// DotFilesAllow serves dot files and directories like any other file.
+	// This is the zero value, kept for backwards compatibility.
+	DotFilesAllow DotFilesPolicy = iota
+	// DotFilesIgnore responds with 404 Not Found, as if the file did not exist.
+	DotFilesIgnore
+	// DotFilesDeny responds with 403 Forbidden.
+	DotFilesDeny
+)
+
+// wellKnownDir is the root directory of the well-known URIs (RFC 8615),
+// served even when the `Options.DotFiles` hide the rest of the dot files.
+const wellKnownDir = ".well-known"
+
+// validatePatterns panics on malformed glob patterns of an `Options` "field",
+// so they are reported on `FileServer` creation instead of serve-time.
+func validatePatterns(field string, patterns []string) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			panic(fmt.Sprintf("FileServer: bad %s pattern %q: %v", field, pattern, err))
+		}
+	}
+}
+
+// matchPath reports whether the "pattern" matches the cleaned, slash-prefixed "name".
+// A pattern without a slash is matched against each path segment of the "name",
+// otherwise it is matched against the "name" and its parent directories.
+func matchPath(pattern, name string) bool {
+	if strings.Contains(pattern, "/") {
+		for p := name; ; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+
+			if p == "/" {
+				return false
+			}
+		}
+	}
+
+	for _, segment := range strings.Split(name[1:], "/") {
+		if ok, _ := path.Match(pattern, segment); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAny reports whether any of the "patterns" matches the "name" (see `matchPath`),
+// an empty "patterns" matches all names.
+func matchAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if matchPath(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hides reports whether any of the options may hide a file.
+func (opts *Options) hides() bool {
+	return opts.DotFiles != DotFilesAllow || len(opts.Deny) > 0 || opts.DeployFiles
+}
+
+// hiddenStatus reports the status code that should be sent
+// when "name" is not allowed to be served, listed or pushed
+// because of the `DotFiles` and `Deny` fields.
+// It returns zero when the "name" is visible.
+func (opts *Options) hiddenStatus(name string) int {
+	if !opts.hides() {
+		return 0
+	}
+
+	name = path.Clean("/" + name)
+
+	if opts.DeployFiles && (name == headersFile || name == redirectsFile) {
+		return http.StatusNotFound
+	}
+
+	for _, pattern := range opts.Deny {
+		if matchPath(pattern, name) {
+			return http.StatusForbidden
+		}
+	}
+
+	if opts.DotFiles != DotFilesAllow {
+		for i, segment := range strings.Split(name[1:], "/") {
+			if i == 0 && segment == wellKnownDir {
+				continue
+			}
+
+			if len(segment) > 1 && segment[0] == '.' {
+				if opts.DotFiles == DotFilesDeny {
+					return http.StatusForbidden
+				}
+
+				return http.StatusNotFound
+			}
+		}
+	}
+
+	return 0
+}
+
+// filterHidden returns the "names" that are not hidden.
+func (opts *Options) filterHidden(names []string) []string {
+	if !opts.hides() {
+		return names
+	}
+
+	visible := names[:0:0]
+	for _, name := range names {
+		if opts.hiddenStatus(name) == 0 {
+			visible = append(visible, name)
+		}
+	}
+
+	return visible
+}
+
+// visibleDir is a directory http.File which
+// omits the hidden children (and the ones the client is not allowed to access)
+// from its `Readdir` results, so that `DirListFunc` implementations don't have to.
+type visibleDir struct {
+	http.File
+	name    string // the request path of the directory.
+	opts    *Options
+	allowed func(name string) bool // see `Options.Auth`.
+}
+
+func (d *visibleDir) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := d.File.Readdir(count)
+	if err != nil {
+		return infos, err
+	}
+
+	// do not filter in-place, the cached directories share their children.
+	visible := make([]os.FileInfo, 0, len(infos))
+	for _, info := range infos {
+		name := path.Join(d.name, toBaseName(info.Name()))
+		if d.opts.hiddenStatus(name) == 0 && (d.allowed == nil || d.allowed(name)) {
+			visible = append(visible, info)
+		}
+	}
+
+	return visible, nil
+}
@@ -0,0 +1,74 @@
+package httpfs
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHiddenFiles(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"index.html":                   "index",
+		".env":                         "secret",
+		".git/config":                  "config",
+		"docs/.hidden":                 "hidden",
+		"docs/notes.bak":               "backup",
+		"docs/readme.txt":              "readme",
+		".well-known/security.txt":     "contact",
+		".well-known/.secret":          "secret",
+		".well-known/acme-challenge/t": "token",
+	})
+
+	tests := []struct {
+		policy DotFilesPolicy
+		target string
+		status int
+	}{
+		{DotFilesIgnore, "/.env", http.StatusNotFound},
+		{DotFilesIgnore, "/.git/config", http.StatusNotFound},
+		{DotFilesIgnore, "/docs/.hidden", http.StatusNotFound},
+		{DotFilesIgnore, "/docs/notes.bak", http.StatusForbidden},
+		{DotFilesIgnore, "/docs/readme.txt", http.StatusOK},
+		{DotFilesIgnore, "/.well-known/security.txt", http.StatusOK},
+		{DotFilesIgnore, "/.well-known/acme-challenge/t", http.StatusOK},
+		{DotFilesIgnore, "/.well-known/.secret", http.StatusNotFound},
+		{DotFilesIgnore, "/docs/.well-known", http.StatusNotFound},
+		{DotFilesDeny, "/.env", http.StatusForbidden},
+		{DotFilesDeny, "/.well-known/security.txt", http.StatusOK},
+		{DotFilesAllow, "/.env", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultOptions
+		opts.DotFiles = tt.policy
+		opts.Deny = []string{"*.bak"}
+		h := FileServer(http.Dir(root), opts)
+
+		if rec := serveTest(h, http.MethodGet, tt.target); rec.Code != tt.status {
+			t.Errorf("%d %s: expected status %d but got %d", tt.policy, tt.target, tt.status, rec.Code)
+		}
+	}
+}
+
+func TestHiddenFilesDirList(t *testing.T) {
+	root := testDir(t, map[string]string{
+		".env":           "secret",
+		"docs/notes.bak": "backup",
+		"readme.txt":     "readme",
+	})
+
+	opts := DefaultOptions
+	opts.ShowList = true
+	opts.Deny = []string{"*.bak"}
+	h := FileServer(http.Dir(root), opts)
+
+	body := serveTest(h, http.MethodGet, "/").Body.String()
+	if strings.Contains(body, ".env") || !strings.Contains(body, "readme.txt") {
+		t.Fatalf("expected the dot files to be omitted from the listing but got:\n%s", body)
+	}
+
+	body = serveTest(h, http.MethodGet, "/docs/").Body.String()
+	if strings.Contains(body, "notes.bak") {
+		t.Fatalf("expected the denied files to be omitted from the listing but got:\n%s", body)
+	}
+}
@@ -10,23 +10,33 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/kataras/compress"
 	"golang.org/x/time/rate"
 )
 
 // Prefix returns a http.Handler that adds a "prefix" to the request path.
 // Use the `PrefixDir` instead when you don't want to alter the request path.
+// Request paths that try to escape the "prefix" are answered with 400 Bad Request,
+// see `ErrInvalidPath`.
 func Prefix(prefix string, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		r.URL.Path = path.Join(prefix, r.URL.Path)
+		name, err := joinPath(prefix, r.URL.Path)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		r.URL.Path = name
 		h.ServeHTTP(w, r)
 	})
 }
 
 // PrefixDir returns a new FileSystem that opens files
 // by adding the given "prefix" to the directory tree of "fs".
+// Names that try to escape the "prefix" fail with `ErrInvalidPath`.
 func PrefixDir(prefix string, fs http.FileSystem) http.FileSystem {
 	if r, ok := fs.(ropener); ok {
 		return &prefixedRopener{prefix, fs, r}
@@ -37,6 +47,7 @@ func PrefixDir(prefix string, fs http.FileSystem) http.FileSystem {
 
 // PrefixFS returns a new FileSystem that opens files
 // by adding the given "prefix" to the directory tree of "fileSystem".
+// Names that try to escape the "prefix" fail with `ErrInvalidPath`.
 //
 // Usage with embed.FS and fs.FS:
 // import "io/fs"
@@ -69,12 +80,29 @@ type (
 )
 
 func (p *prefixedDir) Open(name string) (http.File, error) {
-	name = path.Join(p.prefix, name)
+	name, err := joinPath(p.prefix, name)
+	if err != nil {
+		return nil, err
+	}
+
 	return p.fs.Open(name)
 }
 
+func (p *prefixedRopener) Open(name string) (http.File, error) {
+	name, err := joinPath(p.prefix, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.FileSystem.Open(name)
+}
+
 func (p *prefixedRopener) Ropen(name string, r *http.Request) (http.File, error) {
-	name = path.Join(p.prefix, name)
+	name, err := joinPath(p.prefix, name)
+	if err != nil {
+		return nil, err
+	}
+
 	return p.ropener.Ropen(name, r)
 }
 
@@ -95,10 +123,59 @@ func FileServer(fs http.FileSystem, options Options) http.Handler {
 		options.IndexName = prefix(options.IndexName, "/")
 	}
 
+	indexNames := indexNames(&options)
+	if len(indexNames) > 0 {
+		options.IndexName = indexNames[0]
+	}
+
 	if options.ShowList && options.DirList == nil {
 		options.DirList = DirList
 	}
 
+	if options.ShowList && options.DirListFormats == nil {
+		options.DirListFormats = DefaultDirListFormats
+	}
+
+	for _, format := range options.DirListFormats {
+		if format.DirList == nil || (format.Name == "" && format.MediaType == "") {
+			panic("FileServer: DirListFormats: missing DirList or Name and MediaType")
+		}
+	}
+
+	validatePatterns("Deny", options.Deny)
+	for _, c := range options.CORS {
+		validatePatterns("CORS", c.Patterns)
+	}
+	for _, rule := range options.Headers {
+		validatePatterns("Headers", rule.Patterns)
+	}
+	for _, rule := range options.Auth.Rules {
+		validatePatterns("Auth", rule.Patterns)
+	}
+	for _, rule := range options.Attachments.Rules {
+		validatePatterns("Attachments", rule.Patterns)
+	}
+	for _, limit := range options.Bandwidth {
+		validatePatterns("Bandwidth", limit.Patterns)
+	}
+	for _, limit := range options.Concurrency {
+		if limit.Scope == LimitPerRequest {
+			panic("FileServer: Concurrency: invalid LimitPerRequest scope")
+		}
+		validatePatterns("Concurrency", limit.Patterns)
+	}
+	if options.SignedURLs != nil {
+		validatePatterns("SignedURLs", options.SignedURLs.Patterns)
+	}
+
+	if len(options.SPAIndexes) > 0 {
+		spaIndexes := make(map[string]string, len(options.SPAIndexes))
+		for p, index := range options.SPAIndexes {
+			spaIndexes[prefix(p, "/")] = prefix(index, "/")
+		}
+		options.SPAIndexes = spaIndexes
+	}
+
 	// Make sure PushTarget's paths are in the proper form.
 	for path, filenames := range options.PushTargets {
 		for idx, filename := range filenames {
@@ -107,226 +184,486 @@ func FileServer(fs http.FileSystem, options Options) http.Handler {
 		options.PushTargets[path] = filenames
 	}
 
-	open := func(name string, _ *http.Request) (http.File, error) {
-		return fs.Open(name)
+	s := &fileServer{
+		fs:      fs,
+		options: options,
+		open: func(name string, _ *http.Request) (http.File, error) {
+			return fs.Open(name)
+		},
+		allow:      allowedMethods(options.MethodHandlers),
+		indexNames: indexNames,
 	}
 
 	if r, ok := fs.(ropener); ok {
-		open = r.Ropen
+		s.open = r.Ropen
+	}
+
+	for _, limit := range options.Bandwidth {
+		if limit.Limit > 0 {
+			s.bandwidth = append(s.bandwidth, newBandwidthLimiter(limit))
+		}
+	}
+
+	// the per client slots are taken first, so a client queued
+	// on its own limit does not hold the global slots meanwhile.
+	for _, scope := range []LimitScope{LimitPerClient, LimitGlobal} {
+		for _, limit := range options.Concurrency {
+			if limit.Scope == scope && limit.Max > 0 {
+				s.concurrency = append(s.concurrency, newConcurrencyLimiter(limit))
+			}
+		}
+	}
+
+	if len(options.PushTargetsRegexp) > 0 && !options.PushTargetsLive {
+		s.pushSet() // find the matched files once, instead of on each request.
+	}
+
+	return s
+}
+
+// fileServer is the http.Handler which `FileServer` returns.
+type fileServer struct {
+	fs      http.FileSystem
+	options Options
+	open    func(name string, r *http.Request) (http.File, error)
+	allow   string // the "Allow" header value.
+
+	indexNames []string // see `Options.IndexNames`.
+
+	assets sync.Map // map[string]*indexAssets, see `Options.PushAssets`.
+
+	bandwidth   []*bandwidthLimiter   // see `Options.Bandwidth`.
+	concurrency []*concurrencyLimiter // see `Options.Concurrency`.
+
+	pushes atomic.Pointer[pushSet] // see `Options.PushTargetsRegexp`.
+	pushMu sync.Mutex
+
+	deploy   atomic.Pointer[deployConfig] // see `Options.DeployFiles`.
+	deployMu sync.Mutex
+}
+
+var _ http.Handler = (*fileServer)(nil)
+
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.options.AccessLog == nil {
+		var entry AccessLogEntry // not used.
+		s.serve(w, r, &entry)
+		return
+	}
+
+	entry := &AccessLogEntry{
+		Time:    time.Now(),
+		Request: r,
+		Status:  http.StatusOK,
+	}
+
+	s.serve(newAccessLogWriter(w, entry), r, entry)
+
+	entry.Duration = time.Since(entry.Time)
+	entry.Encoding = w.Header().Get("Content-Encoding")
+	s.options.AccessLog(entry)
+}
+
+// serve serves the request and fills the "entry"
+// with the information the `Options.AccessLog` needs.
+func (s *fileServer) serve(w http.ResponseWriter, r *http.Request, entry *AccessLogEntry) {
+	// the patterns (e.g. `Auth.Rules`) should see the same path the file system opens.
+	name := cleanPath(r.URL.Path)
+	r.URL.Path = name
+	entry.Name = name
+	requestPath := name
+
+	var (
+		indexFound bool
+		noRedirect bool
+		page       bool // a page served by its clean URL, see `Options.Extensions`.
+	)
+
+	if status := s.options.hiddenStatus(name); status != 0 {
+		s.writeError(w, r, status)
+		return
+	}
+
+	if s.serveCORS(w, r, name) {
+		return
+	}
+
+	// plain OPTIONS requests are answered before the authorization,
+	// the `Options.MethodHandlers` after it.
+	if r.Method == http.MethodOptions && s.options.MethodHandlers[r.Method] == nil && s.serveMethod(w, r) {
+		return
 	}
 
-	handler := func(w http.ResponseWriter, r *http.Request) {
-		name := prefix(r.URL.Path, "/")
+	signed := &signatureCheck{signer: s.options.SignedURLs, r: r}
+	if !signed.allowed(name) {
+		s.writeError(w, r, http.StatusForbidden)
+		return
+	}
+
+	auth := &authorizer{s: s, r: r}
+	if status := auth.status(name); status != 0 {
+		s.writeAuthError(w, r, status)
+		return
+	}
+
+	if target, handled := s.decide(w, r, entry, name); handled {
+		return
+	} else if target != "" {
+		if status := s.options.hiddenStatus(target); status != 0 {
+			s.writeError(w, r, status)
+			return
+		}
+
+		name = target
 		r.URL.Path = name
+		entry.Name = name
+		noRedirect = true // the target may be an index file.
+	}
 
-		var (
-			indexFound bool
-			noRedirect bool
-		)
+	if s.serveMethod(w, r) {
+		return
+	}
 
-		f, err := open(name, r)
-		if err != nil {
-			if options.SPA && name != options.IndexName {
-				oldname := name
-				name = prefix(options.IndexName, "/") // to match push targets.
-				r.URL.Path = name
-				f, err = open(name, r) // try find the main index.
-				if err != nil {
-					r.URL.Path = oldname
-					w.WriteHeader(http.StatusNotFound)
-					return
-				}
+	if s.options.TrailingSlash == TrailingSlashStrip && name != "/" && strings.HasSuffix(name, "/") {
+		localRedirect(w, r, "../"+path.Base(name))
+		return
+	}
 
-				indexFound = true // to support push targets.
-				noRedirect = true // to disable redirecting back to /.
-			} else {
-				w.WriteHeader(http.StatusNotFound)
-				return
-			}
+	f, err := s.open(name, r)
+	if errors.Is(err, fs.ErrNotExist) && len(s.options.Extensions) > 0 {
+		// clean URLs, e.g. "/about" serves the "/about.html" file.
+		if fExt, extName, ok := s.openExtension(r, name); ok {
+			f, err = fExt, nil
+			name = extName // keep the request path as it is, to match push targets.
+			entry.Name = name
+			indexFound = true // pages are treated like index files.
+			noRedirect = true
+			page = true
 		}
+	}
 
-		defer f.Close()
+	if target, handled := s.serveRedirects(w, r, s.deployConfig(r), requestPath, name, err == nil); handled || target != "" {
+		if err == nil {
+			f.Close()
+		}
 
-		info, err := f.Stat()
-		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
+		if handled {
 			return
 		}
 
-		//	var indexDirectory http.File
-		// use contents of index.html for directory, if present
-		if info.IsDir() && options.IndexName != "" {
-			index := strings.TrimSuffix(name, "/") + options.IndexName
-			fIndex, err := open(index, r)
-			if err == nil {
-				defer fIndex.Close()
-				infoIndex, err := fIndex.Stat()
-				if err == nil {
-					//		indexDirectory = f
-					indexFound = true
-					info = infoIndex
-					f = fIndex
-				}
-			}
+		if status := s.options.hiddenStatus(target); status != 0 {
+			s.writeError(w, r, status)
+			return
 		}
 
-		// Still a directory? (we didn't find an index.html file)
-		if info.IsDir() {
-			if !options.ShowList {
-				w.WriteHeader(http.StatusNotFound)
-				return
-			}
+		// serve the rewrite target instead.
+		name = target
+		r.URL.Path = name
+		entry.Name = name
+		noRedirect = true // the target may be an index file.
+		f, err = s.open(name, r)
+	}
 
-			if modified, err := checkIfModifiedSince(r, info.ModTime()); !modified && err == nil {
-				writeNotModified(w)
-				return
-			}
-			writeLastModified(w, info.ModTime())
-			err = options.DirList(w, r, options, info.Name(), f)
+	if err != nil {
+		s.reportError(r, name, StageOpen, err)
+
+		// only a missing file can fallback to the SPA index,
+		// permission and I/O errors are reported as they are.
+		if errors.Is(err, fs.ErrNotExist) && s.spaFallback(r, name) {
+			fIndex, index, err := s.openSPAIndex(r, name)
 			if err != nil {
-				// Note: a log can be added here.
-				w.WriteHeader(http.StatusInternalServerError)
+				s.writeError(w, r, errorStatus(err))
 				return
 			}
 
+			f = fIndex
+			name = index // to match push targets.
+			r.URL.Path = name
+			indexFound = true // to support push targets.
+			noRedirect = true // to disable redirecting back to /.
+			entry.Name = name
+			entry.SPA = true
+		} else {
+			s.writeError(w, r, errorStatus(err))
 			return
 		}
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		s.reportError(r, name, StageStat, err)
+		s.writeError(w, r, errorStatus(err))
+		return
+	}
 
-		// index requested, send a moved permanently status
-		// and navigate back to the route without the index suffix.
-		if !noRedirect && options.IndexName != "" && strings.HasSuffix(name, options.IndexName) {
-			localRedirect(w, r, "./")
+	if info.IsDir() && s.options.TrailingSlash == TrailingSlashAdd && !strings.HasSuffix(name, "/") {
+		localRedirect(w, r, path.Base(name)+"/")
+		return
+	}
+
+	// use contents of the first found index file for directory, if present
+	// (a hidden index file is not resolved).
+	if info.IsDir() && len(s.indexNames) > 0 {
+		// a directory without an index file is not a failure.
+		if fIndex, infoIndex, index, err := s.openIndex(r, name, StageIndex); err == nil {
+			defer fIndex.Close()
+			indexFound = true
+			info = infoIndex
+			f = fIndex
+			entry.Name = index
+			entry.Index = true
+		}
+	}
+
+	// the served file may differ from the request path, e.g. an index or a SPA fallback.
+	if entry.Name != requestPath {
+		if !signed.allowed(entry.Name) {
+			s.writeError(w, r, http.StatusForbidden)
 			return
 		}
 
-		if options.Allow != nil {
-			if !options.Allow(w, r, name) { // status code should be written.
-				return
-			}
+		if status := auth.status(entry.Name); status != 0 {
+			s.writeAuthError(w, r, status)
+			return
 		}
 
-		var content io.ReadSeeker = f
+		// a rewrite is not followed again.
+		if _, handled := s.decide(w, r, entry, entry.Name); handled {
+			return
+		}
+	}
 
-		// if not index file and attachments should be force-sent:
-		if !indexFound && options.Attachments.Enable {
-			destName := info.Name()
+	// Still a directory? (we didn't find an index.html file)
+	if info.IsDir() {
+		if !s.options.ShowList {
+			s.writeError(w, r, http.StatusNotFound)
+			return
+		}
 
-			if nameFunc := options.Attachments.NameFunc; nameFunc != nil {
-				destName = nameFunc(destName)
+		dirList, ctype := s.options.DirList, "text/html"
+		if len(s.options.DirListFormats) > 0 {
+			addVary(w.Header(), "Accept")
+			if format := s.dirListFormat(r); format != nil {
+				dirList, ctype = format.DirList, format.MediaType
 			}
+		}
 
-			w.Header().Set("Content-Disposition", "attachment;filename="+destName)
+		if modified, err := checkIfModifiedSince(r, info.ModTime()); !modified && err == nil {
+			writeNotModified(w)
+			return
+		}
+		writeLastModified(w, info.ModTime())
+		s.writeHeaderRules(w, r, requestPath, name, ctype, true) // dynamic, like index files.
+		if auth.done || signed.done {
+			privateCache(w.Header())
+		}
+		dir := &visibleDir{File: f, name: name, opts: &s.options, allowed: func(name string) bool {
+			return auth.allowed(name) && s.authorized(r, name)
+		}}
+		err = dirList(w, r, s.options, info.Name(), dir)
+		if err != nil {
+			s.reportError(r, name, StageDirList, err)
+			s.writeError(w, r, http.StatusInternalServerError)
+			return
+		}
 
-			if options.Attachments.Limit > 0 {
-				content = &rateReadSeeker{
-					ReadSeeker: f,
-					ctx:        r.Context(),
-					limiter:    rate.NewLimiter(rate.Limit(options.Attachments.Limit), options.Attachments.Burst),
-				}
-			}
+		return
+	}
+
+	// index requested, send a moved permanently status
+	// and navigate back to the route without the index suffix.
+	if !noRedirect && s.isIndex(name) {
+		s.redirectToDir(w, r, name)
+		return
+	}
+
+	// page requested with its extension, navigate back to the clean URL.
+	if !noRedirect && s.options.CleanURLs {
+		if clean, ok := s.cleanURL(r, name); ok {
+			localRedirect(w, r, path.Base(clean))
+			return
 		}
+	}
 
-		pusher, ok := w.(http.Pusher) // before compress writer.
+	if s.options.Allow != nil {
+		if !s.options.Allow(w, r, name) { // status code should be written.
+			return
+		}
+	}
+
+	if len(s.concurrency) > 0 && r.Method != http.MethodHead {
+		release, ok := s.acquireDownload(w, r, entry.Name)
 		if !ok {
-			pusher = nil
+			return
 		}
+		defer release()
+	}
 
-		// the encoding saved from the negotiation.
-		encoding, isCached := GetEncoding(f)
-		if isCached {
-			// if it's cached and its settings didnt allow this file to be compressed
-			// then don't try to compress it on the fly, even if the options.Compress was set to true.
-			if encoding != "" {
-				// Set the response header we need, the data are already compressed.
-				compress.AddCompressHeaders(w.Header(), encoding)
-			}
-		} else if options.Compress {
-			cr, err := compress.NewResponseWriter(w, r, -1)
-			if err == nil {
-				defer cr.Close()
-				w = cr
+	s.writeHeaderRules(w, r, requestPath, entry.Name, "", indexFound)
+	if auth.done || signed.done { // shared caches should not store them.
+		privateCache(w.Header())
+	}
+
+	var content io.ReadSeeker = f
+
+	// if not index file and attachments should be force-sent
+	// (signed URLs may force the file to be downloaded too):
+	attachment, isAttachment := s.options.Attachments.attachment(r, entry.Name)
+	if (!indexFound && isAttachment) || signed.download {
+		destName := info.Name()
+
+		if nameFunc := attachment.NameFunc; nameFunc != nil {
+			destName = nameFunc(r, destName)
+		}
+
+		w.Header().Set("Content-Disposition", contentDisposition("attachment", destName))
+
+		if attachment.Limit > 0 {
+			content = &rateReadSeeker{
+				ReadSeeker: f,
+				ctx:        r.Context(),
+				limiters:   []*rate.Limiter{rate.NewLimiter(rate.Limit(attachment.Limit), burstOf(attachment.Limit, attachment.Burst))},
 			}
 		}
+	}
 
-		if (len(options.PushTargets) > 0 || len(options.PushTargetsRegexp) > 0) &&
-			pusher != nil && indexFound && !options.Attachments.Enable {
+	if len(s.bandwidth) > 0 {
+		limiters, release := s.bandwidthLimiters(r, entry.Name)
+		defer release()
 
-			var pushOpts *http.PushOptions
-			if encoding != "" {
-				// pushOpts = &http.PushOptions{Header: http.Header{
-				// 	"Accept-Encoding": r.Header["Accept-Encoding"],
-				// }}
-				// OR just pass the whole current request's headers (e.g. a request id may be assigned).
-				pushOpts = &http.PushOptions{Header: r.Header}
+		if len(limiters) > 0 {
+			if rs, ok := content.(*rateReadSeeker); ok {
+				rs.limiters = append(rs.limiters, limiters...)
+			} else {
+				content = &rateReadSeeker{ReadSeeker: f, ctx: r.Context(), limiters: limiters}
 			}
+		}
+	}
+
+	pusher, ok := w.(http.Pusher) // before compress writer.
+	if !ok {
+		pusher = nil
+	}
 
-			if indexAssets, ok := options.PushTargets[r.URL.Path]; ok {
-				// Let's not try to use relative, give developer a clean control.
-				// rel := r.URL.Path
-				// if !info.IsDir() {
-				// 	rel = path.Dir(rel)
-				// }
-				// path.Join(rel, indexAsset)
-				for _, indexAsset := range indexAssets {
-					if indexAsset[0] != '/' {
-						// it's relative path.
-						indexAsset = path.Join(r.RequestURI, indexAsset)
-					}
-
-					if err = pusher.Push(indexAsset, pushOpts); err != nil {
-						break
-					}
+	// the targets of an index file, pushed or sent as preload links.
+	var (
+		targets []string
+		pushed  func(w http.ResponseWriter, n int) // see `PushOncePerVersion`.
+	)
+	preload := s.options.Preload || s.options.EarlyHints
+	push := pusher != nil && s.options.PushPolicy != PushNever
+	if indexFound && !isAttachment && !signed.download && (push || preload) {
+		targets = s.pushTargets(r, name, requestPath, entry.SPA || page)
+		if s.options.PushAssets {
+			for _, target := range s.assetTargets(r, entry.Name, f, info) {
+				if !contains(targets, target) {
+					targets = append(targets, target)
 				}
 			}
+		}
 
-			if regex, ok := options.PushTargetsRegexp[r.URL.Path]; ok {
-				prefixURL := strings.TrimSuffix(r.RequestURI, name)
-				if prefixURL == "" {
-					prefixURL = "/"
-				}
+		if len(targets) > 0 && len(s.options.Auth.Rules) > 0 {
+			// do not push or preload the files the client cannot access.
+			targets = s.filterAllowed(auth, r, requestPath, targets)
+		}
+
+		if preload && len(targets) > 0 {
+			// before the compress writer, which treats any status code as the final one.
+			s.writePreloadLinks(w, targets)
+			targets = nil // do not push them too.
+		}
+
+		if push && len(targets) > 0 {
+			targets, pushed = s.filterPushed(r, requestPath, targets)
+		}
+	}
+
+	// the encoding saved from the negotiation.
+	encoding, isCached := GetEncoding(f)
+	entry.Cached = isCached
+
+	if pusher != nil && len(targets) > 0 {
+		var pushOpts *http.PushOptions
+		if encoding != "" || len(s.options.Auth.Rules) > 0 { // the pushed requests should be authenticated too.
+			// pushOpts = &http.PushOptions{Header: http.Header{
+			// 	"Accept-Encoding": r.Header["Accept-Encoding"],
+			// }}
+			// OR just pass the whole current request's headers (e.g. a request id may be assigned).
+			pushOpts = &http.PushOptions{Header: r.Header}
+		}
 
-				names, err := findNames(fs, name)
-				if err == nil {
-					for _, indexAsset := range names {
-						// it's an index file, do not pushed that.
-						if strings.HasSuffix("/"+indexAsset, options.IndexName) {
-							continue
-						}
-
-						// match using relative path (without the first '/' slash)
-						// to keep consistency between the `PushTargets` behavior
-						if regex.MatchString(indexAsset) {
-							// println("Pushing: " + path.Join(prefixURL, indexAsset))
-							if err = pusher.Push(path.Join(prefixURL, indexAsset), pushOpts); err != nil {
-								break
-							}
-						}
-					}
+		n := 0
+		for _, target := range targets {
+			if err = pusher.Push(target, pushOpts); err != nil {
+				if !errors.Is(err, http.ErrNotSupported) {
+					s.reportError(r, target, StagePush, err)
 				}
+				break
 			}
+			n++
+		}
+
+		if pushed != nil {
+			// only the targets actually pushed, before the compress writer.
+			pushed(w, n)
+		}
+	}
+
+	if isCached {
+		// if it's cached and its settings didnt allow this file to be compressed
+		// then don't try to compress it on the fly, even if the s.options.Compress was set to true.
+		if encoding != "" {
+			// Set the response header we need, the data are already compressed.
+			addCompressHeaders(w.Header(), encoding)
+		}
+	} else if s.options.Compress {
+		var done func()
+		w, _, done = s.compressResponse(w, r, name)
+		defer done()
+	}
+
+	if entry.SPA && s.options.SPAStatus > 0 && s.options.SPAStatus != http.StatusOK {
+		// a soft 404 page is always sent as a whole.
+		for _, key := range []string{"If-Modified-Since", "If-None-Match", "If-Unmodified-Since", "If-Match", "If-Range", "Range"} {
+			r.Header.Del(key)
 		}
 
-		http.ServeContent(w, r, info.Name(), info.ModTime(), content)
+		w = &statusWriter{ResponseWriter: w, status: s.options.SPAStatus}
 	}
 
-	return http.HandlerFunc(handler)
+	http.ServeContent(w, r, info.Name(), info.ModTime(), content)
 }
 
 // rateReadSeeker is a io.ReadSeeker that is rate limited by
-// the given token bucket. Each token in the bucket
+// the given token buckets. Each token in the bucket
 // represents one byte. See "golang.org/x/time/rate" package.
 type rateReadSeeker struct {
 	io.ReadSeeker
-	ctx     context.Context
-	limiter *rate.Limiter
+	ctx      context.Context
+	limiters []*rate.Limiter
 }
 
 func (rs *rateReadSeeker) Read(buf []byte) (int, error) {
+	// do not read more than a bucket can hold at once.
+	for _, limiter := range rs.limiters {
+		if burst := limiter.Burst(); burst > 0 && len(buf) > burst {
+			buf = buf[:burst]
+		}
+	}
+
 	n, err := rs.ReadSeeker.Read(buf)
 	if n <= 0 {
 		return n, err
 	}
-	err = rs.limiter.WaitN(rs.ctx, n)
+
+	for _, limiter := range rs.limiters {
+		if waitErr := limiter.WaitN(rs.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
 	return n, err
 }
 
@@ -414,6 +751,17 @@ func localRedirect(w http.ResponseWriter, r *http.Request, newPath string) {
 	w.WriteHeader(http.StatusMovedPermanently)
 }
 
+// cleanPath returns the slash-prefixed, cleaned "p" (see `path.Clean`),
+// keeping its trailing slash, e.g. "/private//report.pdf" to "/private/report.pdf".
+func cleanPath(p string) string {
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
+
 func prefix(s string, prefix string) string {
 	if !strings.HasPrefix(s, prefix) {
 		return prefix + s
@@ -0,0 +1,89 @@
+package httpfs
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// indexNames returns the index file names of the "opts" in priority order,
+// the `Options.IndexNames` or the `Options.IndexName` one.
+func indexNames(opts *Options) []string {
+	if len(opts.IndexNames) == 0 {
+		if opts.IndexName == "" {
+			return nil
+		}
+
+		return []string{opts.IndexName}
+	}
+
+	names := make([]string, 0, len(opts.IndexNames))
+	for _, name := range opts.IndexNames {
+		if name != "" {
+			names = append(names, prefix(name, "/"))
+		}
+	}
+
+	return names
+}
+
+// isIndex reports whether the "name" ends with one of the index file names.
+func (s *fileServer) isIndex(name string) bool {
+	for _, indexName := range s.indexNames {
+		if strings.HasSuffix(name, indexName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// openIndex tries the index file names of the "dir" directory in priority order
+// and returns the first one that exists and it is not hidden or a directory.
+// Failures other than a missing file are reported with the "stage"
+// and the first of them is returned when no index file was found.
+func (s *fileServer) openIndex(r *http.Request, dir string, stage ErrorStage) (http.File, os.FileInfo, string, error) {
+	var firstErr error
+	for _, indexName := range s.indexNames {
+		index := strings.TrimSuffix(dir, "/") + indexName
+		if s.options.hiddenStatus(index) != 0 {
+			continue
+		}
+
+		f, err := s.open(index, r)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				s.reportError(r, index, stage, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+			continue
+		}
+
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			s.reportError(r, index, stage, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if info.IsDir() {
+			f.Close()
+			continue
+		}
+
+		return f, info, index, nil
+	}
+
+	if firstErr == nil {
+		firstErr = fs.ErrNotExist
+	}
+
+	return nil, nil, "", firstErr
+}
@@ -0,0 +1,90 @@
+package httpfs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIndexNames(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"index.htm":       "root htm",
+		"index.html":      "root html",
+		"docs/index.html": "docs html",
+		"blog/index.htm":  "blog htm",
+		"blog/index.html": "blog html",
+		"empty/a.txt":     "a",
+	})
+
+	opts := DefaultOptions
+	opts.IndexNames = []string{"index.htm", "/index.html"}
+	h := FileServer(http.Dir(root), opts)
+
+	tests := []struct {
+		target   string
+		status   int
+		body     string
+		location string
+	}{
+		{"/", http.StatusOK, "root htm", ""},
+		{"/docs/", http.StatusOK, "docs html", ""},
+		{"/blog/", http.StatusOK, "blog htm", ""},
+		{"/empty/", http.StatusNotFound, "", ""},
+		{"/index.htm", http.StatusMovedPermanently, "", "./"},
+		{"/index.html", http.StatusMovedPermanently, "", "./"},
+		{"/docs/index.html", http.StatusMovedPermanently, "", "./"},
+		{"/blog/index.htm", http.StatusMovedPermanently, "", "./"},
+		{"/blog/index.html", http.StatusMovedPermanently, "", "./"},
+	}
+
+	for _, tt := range tests {
+		rec := serveTest(h, http.MethodGet, tt.target)
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d but got %d", tt.target, tt.status, rec.Code)
+			continue
+		}
+
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s: expected body %q but got %q", tt.target, tt.body, rec.Body.String())
+		}
+
+		if got := rec.Header().Get("Location"); got != tt.location {
+			t.Errorf("%s: expected Location %q but got %q", tt.target, tt.location, got)
+		}
+	}
+}
+
+func TestIndexNamesSPA(t *testing.T) {
+	root := testDir(t, map[string]string{"index.html": "app"})
+
+	var entry *AccessLogEntry
+	opts := DefaultOptions
+	opts.SPA = true
+	opts.IndexNames = []string{"/index.htm", "/index.html"}
+	opts.PushTargets = map[string][]string{
+		"/index.htm":  {"/htm.js"},
+		"/index.html": {"/app.js"},
+	}
+	opts.AccessLog = func(e *AccessLogEntry) { entry = e }
+	h := FileServer(http.Dir(root), opts)
+
+	// the fallback serves and pushes the matched index name.
+	pw := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(pw, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if pw.Code != http.StatusOK || pw.Body.String() != "app" {
+		t.Fatalf("expected the SPA index but got %d: %q", pw.Code, pw.Body.String())
+	}
+
+	if entry.Name != "/index.html" || !entry.SPA {
+		t.Errorf("expected the /index.html SPA fallback but got %q (SPA: %v)", entry.Name, entry.SPA)
+	}
+
+	if len(pw.pushed) != 1 || pw.pushed[0] != "/app.js" {
+		t.Errorf("expected the /app.js push but got %v", pw.pushed)
+	}
+
+	// a missing index file does not fallback.
+	if rec := serveTest(h, http.MethodGet, "/index.htm"); rec.Code != http.StatusNotFound {
+		t.Errorf("/index.htm: expected status 404 but got %d", rec.Code)
+	}
+}
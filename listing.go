@@ -0,0 +1,165 @@
+package httpfs
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DirListFormat is a directory listing format other than the HTML `Options.DirList`,
+// selected by the "format" query parameter or the "Accept" request header.
+// See `Options.DirListFormats`.
+type DirListFormat struct {
+	// Name is the "format" query parameter value, e.g. "json" for "?format=json".
+	Name string
+	// MediaType is the negotiated "Accept" media type, e.g. "application/json".
+	MediaType string
+	// DirList renders the listing, e.g. `DirListJSON`.
+	DirList DirListFunc
+}
+
+// DefaultDirListFormats holds the default `Options.DirListFormats`,
+// the JSON listing of `DirListJSON`.
+var DefaultDirListFormats = []DirListFormat{
+	{Name: "json", MediaType: "application/json", DirList: DirListJSON},
+}
+
+// DirEntry is a directory entry of a structured listing,
+// e.g. `DirListJSON`. See `DirEntries`.
+type DirEntry struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"` // the request URL path.
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"modtime"`
+	IsDir    bool      `json:"is_dir"`
+	Mode     string    `json:"mode"`
+	MIMEType string    `json:"mime_type,omitempty"`
+	Download bool      `json:"download"` // see `Attachments.Rules`.
+}
+
+// DirEntries returns the entries of the "dir" directory sorted by name,
+// a helper for custom `DirListFormat` implementations, e.g. NDJSON or CSV.
+func DirEntries(r *http.Request, dirOptions Options, dir http.File) ([]DirEntry, error) {
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+	// the request path as the client sent it, before any http.StripPrefix.
+	uri := r.RequestURI
+	if idx := strings.IndexByte(uri, '?'); idx != -1 {
+		uri = uri[:idx]
+	}
+	if unescaped, err := url.PathUnescape(uri); err == nil {
+		uri = unescaped
+	}
+
+	entries := make([]DirEntry, 0, len(infos))
+	for _, info := range infos {
+		name := toBaseName(info.Name())
+
+		entry := DirEntry{
+			Name:    name,
+			Path:    (&url.URL{Path: path.Join(uri, name)}).String(),
+			Size:    info.Size(),
+			ModTime: info.ModTime().UTC(),
+			IsDir:   info.IsDir(),
+			Mode:    info.Mode().String(),
+		}
+
+		if !entry.IsDir {
+			entry.MIMEType = mime.TypeByExtension(path.Ext(name))
+			_, entry.Download = dirOptions.Attachments.attachment(nil, path.Join(r.URL.Path, name))
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// DirListJSON is a `DirListFunc` which renders the directory entries as JSON, e.g.
+//
+//	{"name":"docs","entries":[{"name":"a.txt","path":"/docs/a.txt","size":1,...}]}
+//
+// See `DefaultDirListFormats`.
+func DirListJSON(w http.ResponseWriter, r *http.Request, dirOptions Options, dirName string, dir http.File) error {
+	entries, err := DirEntries(r, dirOptions, dir)
+	if err != nil {
+		return err
+	}
+
+	writeContentType(w, "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(struct {
+		Name    string     `json:"name"`
+		Entries []DirEntry `json:"entries"`
+	}{dirName, entries})
+}
+
+// dirListFormat returns the `Options.DirListFormats` entry the client asked for,
+// by the "format" query parameter or the "Accept" header, or nil for the HTML listing.
+func (s *fileServer) dirListFormat(r *http.Request) *DirListFormat {
+	formats := s.options.DirListFormats
+	if len(formats) == 0 {
+		return nil
+	}
+
+	if name := r.URL.Query().Get("format"); name != "" {
+		for i := range formats {
+			if strings.EqualFold(formats[i].Name, name) {
+				return &formats[i]
+			}
+		}
+
+		return nil
+	}
+
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+
+	var ranges []mediaRange
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			params := strings.Split(part, ";")
+			mr := mediaRange{mediaType: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+			for _, param := range params[1:] {
+				if param = strings.TrimSpace(param); strings.HasPrefix(param, "q=") {
+					if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+						mr.q = q
+					}
+				}
+			}
+
+			if mr.mediaType != "" && mr.q > 0 {
+				ranges = append(ranges, mr)
+			}
+		}
+	}
+
+	// the most preferred first, the HTML listing wins the wildcards.
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	for _, mr := range ranges {
+		switch mr.mediaType {
+		case "text/html", "application/xhtml+xml", "text/*", "*/*":
+			return nil
+		}
+
+		for i := range formats {
+			if strings.EqualFold(formats[i].MediaType, mr.mediaType) {
+				return &formats[i]
+			}
+		}
+	}
+
+	return nil
+}
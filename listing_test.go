@@ -0,0 +1,113 @@
+package httpfs
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDirListFormats(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"a.zip":         "zip",
+		"sub dir/ü.txt": "txt",
+		".hidden":       "hidden",
+	})
+
+	opts := DefaultOptions
+	opts.ShowList = true
+	opts.Attachments = Attachments{Rules: []AttachmentRule{{Patterns: []string{"*.zip"}}}}
+	h := FileServer(http.Dir(root), opts)
+
+	tests := []struct {
+		target string
+		header []string
+		json   bool
+	}{
+		{"/", nil, false},
+		{"/", []string{"Accept", "text/html,application/xhtml+xml,*/*;q=0.8"}, false},
+		{"/", []string{"Accept", "text/html,application/json;q=0.9"}, false},
+		{"/", []string{"Accept", "application/json"}, true},
+		{"/", []string{"Accept", "application/json, text/html;q=0.5"}, true},
+		{"/?format=json", []string{"Accept", "text/html"}, true},
+		{"/?format=csv", nil, false},
+	}
+
+	for _, tt := range tests {
+		rec := serveTest(h, http.MethodGet, tt.target, tt.header...)
+		isJSON := strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json")
+		if isJSON != tt.json {
+			t.Errorf("%s %v: expected JSON: %v but got %q", tt.target, tt.header, tt.json, rec.Header().Get("Content-Type"))
+		}
+
+		if rec.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s %v: expected Vary: Accept but got %q", tt.target, tt.header, rec.Header().Get("Vary"))
+		}
+	}
+
+	var listing struct {
+		Name    string     `json:"name"`
+		Entries []DirEntry `json:"entries"`
+	}
+
+	rec := serveTest(h, http.MethodGet, "/sub%20dir/", "Accept", "application/json")
+	if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+		t.Fatal(err)
+	}
+
+	if listing.Name != "sub dir" || len(listing.Entries) != 1 {
+		t.Fatalf("unexpected listing: %+v", listing)
+	}
+
+	if entry := listing.Entries[0]; entry.Name != "ü.txt" || entry.Path != "/sub%20dir/%C3%BC.txt" ||
+		entry.Size != 3 || entry.IsDir || !strings.HasPrefix(entry.MIMEType, "text/plain") || entry.Download {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+
+	rec = serveTest(h, http.MethodGet, "/?format=json")
+	if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(listing.Entries) != 2 || !listing.Entries[0].Download || !listing.Entries[1].IsDir {
+		t.Fatalf("expected the hidden files to be omitted and a.zip to be downloaded but got %+v", listing.Entries)
+	}
+}
+
+func TestDirListFormatsCustom(t *testing.T) {
+	root := testDir(t, map[string]string{"a.txt": "a", "b.txt": "bb"})
+
+	csv := func(w http.ResponseWriter, r *http.Request, dirOptions Options, dirName string, dir http.File) error {
+		entries, err := DirEntries(r, dirOptions, dir)
+		if err != nil {
+			return err
+		}
+
+		writeContentType(w, "text/csv")
+		for _, entry := range entries {
+			w.Write([]byte(entry.Name + "," + entry.Path + "\n"))
+		}
+
+		return nil
+	}
+
+	opts := DefaultOptions
+	opts.ShowList = true
+	opts.DirListFormats = append(DefaultDirListFormats, DirListFormat{Name: "csv", MediaType: "text/csv", DirList: csv})
+	h := FileServer(http.Dir(root), opts)
+
+	for _, rec := range []interface{ String() string }{
+		serveTest(h, http.MethodGet, "/?format=csv").Body,
+		serveTest(h, http.MethodGet, "/", "Accept", "text/csv").Body,
+	} {
+		if got := rec.String(); got != "a.txt,/a.txt\nb.txt,/b.txt\n" {
+			t.Errorf("unexpected CSV listing: %q", got)
+		}
+	}
+
+	opts.DirListFormats = []DirListFormat{} // disabled.
+	h = FileServer(http.Dir(root), opts)
+	if rec := serveTest(h, http.MethodGet, "/?format=json"); strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		t.Fatal("expected the JSON listing to be disabled")
+	}
+}
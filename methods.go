@@ -0,0 +1,56 @@
+package httpfs
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// allowedMethods returns the value of the "Allow" response header:
+// GET, HEAD and OPTIONS followed by the `Options.MethodHandlers` ones.
+func allowedMethods(handlers map[string]http.Handler) string {
+	methods := []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+
+	extra := make([]string, 0, len(handlers))
+	for method, h := range handlers {
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			continue
+		}
+
+		if h != nil {
+			extra = append(extra, method)
+		}
+	}
+	sort.Strings(extra)
+
+	return strings.Join(append(methods, extra...), ", ")
+}
+
+// serveMethod handles the requests of methods other than GET and HEAD.
+// OPTIONS requests (including "OPTIONS *") are answered with the "Allow" header,
+// methods registered through `Options.MethodHandlers` are served by their handler
+// and the rest are answered with 405 Method Not Allowed.
+// It reports whether the request was handled.
+func (s *fileServer) serveMethod(w http.ResponseWriter, r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return false
+	}
+
+	if h := s.options.MethodHandlers[r.Method]; h != nil {
+		h.ServeHTTP(w, r)
+		return true
+	}
+
+	w.Header().Set("Allow", s.allow)
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+
+	s.writeError(w, r, http.StatusMethodNotAllowed)
+	return true
+}
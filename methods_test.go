@@ -0,0 +1,70 @@
+package httpfs
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestServeMethod(t *testing.T) {
+	root := testDir(t, map[string]string{"a.txt": "a"})
+
+	put := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	tests := []struct {
+		name     string
+		handlers map[string]http.Handler
+		allow    string
+	}{
+		{"no handlers", nil, "GET, HEAD, OPTIONS"},
+		{"handlers", map[string]http.Handler{
+			http.MethodPut:    put,
+			http.MethodDelete: put,
+			http.MethodGet:    put, // GET and HEAD serve content.
+			http.MethodPatch:  nil,
+		}, "GET, HEAD, OPTIONS, DELETE, PUT"},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultOptions
+		opts.MethodHandlers = tt.handlers
+		h := FileServer(http.Dir(root), opts)
+
+		rec := serveTest(h, http.MethodPost, "/a.txt")
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: POST: expected status 405 but got %d", tt.name, rec.Code)
+		}
+
+		if got := rec.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s: POST: expected Allow %q but got %q", tt.name, tt.allow, got)
+		}
+
+		for _, target := range []string{"/a.txt", "/missing.txt", "*"} {
+			rec = serveTest(h, http.MethodOptions, target)
+			if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+				t.Errorf("%s: OPTIONS %s: expected status 204 without a body but got %d", tt.name, target, rec.Code)
+			}
+
+			if got := rec.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("%s: OPTIONS %s: expected Allow %q but got %q", tt.name, target, tt.allow, got)
+			}
+
+			if got := rec.Header().Get("Content-Length"); got != "0" {
+				t.Errorf("%s: OPTIONS %s: expected Content-Length 0 but got %q", tt.name, target, got)
+			}
+		}
+
+		rec = serveTest(h, http.MethodGet, "/a.txt")
+		if rec.Code != http.StatusOK || rec.Body.String() != "a" {
+			t.Errorf("%s: GET: expected the file but got %d: %q", tt.name, rec.Code, rec.Body.String())
+		}
+
+		rec = serveTest(h, http.MethodPut, "/a.txt")
+		if tt.handlers != nil && rec.Code != http.StatusCreated {
+			t.Errorf("%s: PUT: expected the handler status 201 but got %d", tt.name, rec.Code)
+		} else if tt.handlers == nil && rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: PUT: expected status 405 but got %d", tt.name, rec.Code)
+		}
+	}
+}
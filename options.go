@@ -29,6 +29,7 @@ var DefaultOptions = Options{
 	IndexName: "/index.html",
 	Compress:  true,
 	ShowList:  false,
+	DotFiles:  DotFilesIgnore,
 }
 
 // MatchCommonAssets is a simple regex expression which
@@ -44,6 +45,11 @@ type Options struct {
 	// Defaults to "/index.html", if request path is ending with **/*/$IndexName
 	// then it redirects to **/*(/).
 	IndexName string
+	// IndexNames, if not empty, holds the index file names
+	// of directories in priority order, e.g. "/index.html", "/index.htm", "/default.html".
+	// The first one found is served and all of them redirect back to their directory.
+	// It overrides the `IndexName`, which is set to its first name.
+	IndexNames []string
 	// PushTargets filenames (map's value) to
 	// be served without additional client's requests (HTTP/2 Push)
 	// when a specific request path (map's key WITHOUT prefix)
@@ -63,7 +69,39 @@ type Options struct {
 	// Example:
 	// "/": regexp.MustCompile("((.*).js|(.*).css|(.*).ico)$")
 	// See `MatchCommonAssets` too.
+	//
+	// The matched files are found once, on `FileServer` and on each `Rebuild`
+	// of a `Cache` file system, see `PushTargetsLive` too.
 	PushTargetsRegexp map[string]*regexp.Regexp
+	// PushTargetsLive, if true, finds the `PushTargetsRegexp` files
+	// on each request instead, so added or removed files are noticed
+	// without a restart, e.g. on development.
+	PushTargetsLive bool
+	// PushAssets, if true, discovers the push (or `Preload`) targets of the served index HTML files
+	// by parsing their "script src", "link rel=stylesheet/icon/modulepreload href"
+	// and "img src" references, other origins and lazy images are omitted.
+	// A file is parsed once per contents version, a `Cache` file once per `Rebuild`.
+	// The targets are added to the `PushTargets` and `PushTargetsRegexp` ones.
+	PushAssets bool
+	// PushPolicy controls which of the push targets are pushed to a client,
+	// e.g. `PushOncePerVersion` pushes only the assets (or their versions)
+	// the client was not pushed before. Defaults to `PushAlways`.
+	// The contents versions are read once (see `PushOncePerVersion`),
+	// changed files are noticed on `Rebuild` or with the `PushTargetsLive`.
+	PushPolicy PushPolicy
+	// PushCookie is the name of the cookie which tracks
+	// the pushed targets on `PushOncePerVersion`. Defaults to `DefaultPushCookie`.
+	PushCookie string
+	// Preload, if true, sends the `PushTargets` and `PushTargetsRegexp`
+	// as "Link: <target>; rel=preload; as=..." headers instead of pushing them,
+	// so they reach the HTTP/1.1, HTTP/2 and HTTP/3 clients alike
+	// (browsers have removed the HTTP/2 Push support).
+	// The "as" is inferred from the file extension, fonts are marked as crossorigin.
+	Preload bool
+	// EarlyHints, if true, sends the `Preload` links on a 103 Early Hints response too,
+	// before the file is served, so the client can start fetching them earlier.
+	// It implies `Preload`.
+	EarlyHints bool
 
 	// When files should served under compression.
 	Compress bool
@@ -74,18 +112,172 @@ type Options struct {
 	// of the default one to show the list of files of a current requested directory(dir).
 	// See `DirListRich` package-level function too.
 	DirList DirListFunc
+	// DirListFormats registers directory listing formats other than the HTML `DirList`,
+	// selected by the "format" query parameter (e.g. "?format=json")
+	// or the "Accept" request header (e.g. "application/json").
+	// Defaults to `DefaultDirListFormats` (JSON), set it to an empty slice to disable them.
+	DirListFormats []DirListFormat
 
 	// Files downloaded and saved locally.
 	Attachments Attachments
+	// Bandwidth holds the bytes per second limits of the served files,
+	// per request, per client (e.g. IP or user) or global, see `BandwidthLimit`.
+	// All the matching limits apply, e.g. 1MB per client and 100MB in total.
+	Bandwidth []BandwidthLimit
+	// Concurrency holds the limits of the simultaneous downloads,
+	// per client or global, see `ConcurrencyLimit`. The exceeding downloads
+	// are queued or rejected with 429 Too Many Requests and a "Retry-After" header.
+	Concurrency []ConcurrencyLimit
+
+	// Extensions holds the file extensions, e.g. ".html" and ".htm",
+	// which are tried in order when the requested file does not exist,
+	// so "/about" serves the "/about.html" file.
+	// Pages resolved through an extension are treated like index files,
+	// e.g. their `PushTargets` are looked up by their extension-less request path.
+	Extensions []string
+	// CleanURLs, if true, redirects the requests of files ending with one of the `Extensions`
+	// to their extension-less path, e.g. "/about.html" to "/about",
+	// the same way the `IndexName` file requests are redirected to their directory.
+	CleanURLs bool
+	// TrailingSlash controls whether a trailing slash is added (to directories),
+	// stripped or ignored on request paths. Defaults to `TrailingSlashIgnore`.
+	TrailingSlash TrailingSlashPolicy
+
+	// DotFiles controls how path segments starting with a dot
+	// (e.g. "/.git/config", "/.env") are served.
+	// Hidden files are never listed by `DirList` nor pushed through `PushTargetsRegexp`.
+	// The root "/.well-known" directory (RFC 8615), e.g. of ACME HTTP-01 challenges
+	// and "security.txt", is always served, its own dot children are not.
+	// Defaults to `DotFilesIgnore` (404) on `DefaultOptions`
+	// and to `DotFilesAllow` on a zero `Options` value.
+	DotFiles DotFilesPolicy
+	// Deny holds glob patterns (see `path.Match`) of files and directories
+	// that should never be served, listed or pushed.
+	// A pattern without a slash is matched against each path segment,
+	// e.g. "*.bak" or "node_modules", otherwise it is matched
+	// against the request path and its parent directories, e.g. "/private/*".
+	// Denied requests respond with 403 Forbidden.
+	Deny []string
+
+	// Auth holds the authenticators and the per file pattern rules of the principals
+	// allowed to access them. The rules apply to the requested file,
+	// the index and `SPA` files served instead, the `DirList` entries and the pushed
+	// (or preloaded) files. Unauthenticated clients receive a 401 Unauthorized
+	// with the "WWW-Authenticate" challenges and the rest a 403 Forbidden.
+	Auth Auth
+
+	// SignedURLs, if not nil, requires the GET and HEAD requests of its files
+	// to be signed by its `URLSigner.Sign`. Unsigned, tampered or expired
+	// requests respond with 403 Forbidden. Signed URLs with the
+	// `SignOptions.Download` are sent as `Attachments`, including its limits.
+	SignedURLs *URLSigner
 
 	// Optional validator that loops through each requested resource.
 	// Note: response writer is given to manually write an error code, e.g. 404 or 400.
+	//
+	// Deprecated: it is called after the index resolution, so directory listings bypass it,
+	// use the `Authorize` instead.
 	Allow func(w http.ResponseWriter, r *http.Request, name string) bool
+	// Authorize, if not nil, decides whether a file is served (`Allowed`), denied
+	// with a status code (`Denied`), redirected (`Redirected`) or replaced with another file (`Rewritten`).
+	// It is called with the cleaned request path (e.g. "/x/../old/a.txt" as "/old/a.txt")
+	// before any file is opened, so it applies to directories
+	// and their listings too, and again with the served file name when it differs,
+	// e.g. an index or a `SPA` file (a rewrite is not followed the second time).
+	// The denied `DirList` entries are omitted.
+	Authorize func(r *http.Request, name string) Decision
 
 	// If enabled then the router will render the index file on any not-found file
 	// instead of firing the 404 error code handler.
 	// Make sure the `IndexName` field is set.
 	SPA bool
+	// SPAHTMLOnly, if true, limits the `SPA` fallback to requests
+	// without a file extension which accept a HTML response (e.g. browser navigations),
+	// so a missing "/missing.js" or a "fetch" of an API still responds with 404.
+	SPAHTMLOnly bool
+	// SPAExclude holds request path prefixes that never fallback to
+	// the `SPA` index file, e.g. "/api" excludes "/api" and "/api/users".
+	SPAExclude []string
+	// SPAStatus is the status code of the `SPA` fallback responses,
+	// e.g. http.StatusNotFound to serve the index file as a soft 404 page.
+	// Defaults to 200 OK.
+	SPAStatus int
+	// SPAIndexes maps the path prefixes of nested single page applications
+	// to their own index files, e.g. {"/admin": "/admin/index.html", "/shop": "/shop/index.html"},
+	// so a missing "/admin/users/1" falls back to the "/admin/index.html" one.
+	// The longest matching prefix wins and the rest fallback to the root `IndexName(s)`.
+	// The `SPA` field should be true. The `PushTargets` and `PushTargetsRegexp`
+	// of an app are looked up by its index file name, e.g. "/admin/index.html".
+	SPAIndexes map[string]string
+
+	// MethodHandlers registers handlers for request methods
+	// other than GET and HEAD, which are the only ones serving content,
+	// e.g. a "PUT" or "DELETE" handler of a write-capable extension.
+	// OPTIONS requests are answered with the "Allow" header
+	// listing GET, HEAD, OPTIONS and the registered methods
+	// and any other method is answered with 405 Method Not Allowed.
+	// The handlers are called after the `SignedURLs`, `Auth` and `Authorize` checks.
+	MethodHandlers map[string]http.Handler
+
+	// CORS holds the Cross-Origin Resource Sharing rules.
+	// The first rule that matches the request path is applied,
+	// to all responses including the 304 Not Modified ones,
+	// and preflight requests are answered automatically.
+	CORS []CORS
+
+	// Headers holds the response headers per file pattern or media type,
+	// e.g. Cache-Control, Content-Security-Policy, X-Content-Type-Options
+	// and Referrer-Policy. All the matching rules are applied, in order,
+	// so a later rule overrides the headers of a previous one.
+	// See `DefaultHeaders` too.
+	Headers []HeaderRule
+
+	// DeployFiles, if true, reads the Netlify-style "_headers" and "_redirects" files
+	// from the root of the served file system. The files themselves are never served.
+	//
+	// The "_headers" file holds path patterns followed by indented "Key: Value" lines:
+	//  /assets/*
+	//    Cache-Control: public, max-age=31536000
+	//
+	// The "_redirects" file holds one "from to [status][!]" rule per line:
+	//  /news/:year/*  /blog/:year/:splat  301
+	//  /app/*         /app/index.html     200
+	//  /old           /new                302!
+	// Paths may contain ":placeholder" segments and a trailing "*" splat (":splat" on targets).
+	// Status codes are 301 (default), 302 and the rest of the redirect codes,
+	// 200 to serve the target instead (rewrite) and 404 to serve the target
+	// with a 404 status code. Rules apply only when the requested file does not exist,
+	// unless the status is followed by a "!" (forced rule).
+	// Rules of any other status code are skipped and reported to the `OnError`.
+	//
+	// The files are read again when a `Cache` file system is rebuilt, see `Rebuild`.
+	DeployFiles bool
+
+	// ErrorHandlers registers a handler per error status code, e.g.
+	// http.StatusNotFound, http.StatusForbidden,
+	// http.StatusMethodNotAllowed and http.StatusInternalServerError.
+	// The handler is responsible to write the status code.
+	// It takes precedence over the `ErrorPages`.
+	ErrorHandlers map[int]http.Handler
+	// ErrorPages maps an error status code to a file of the served file system
+	// which is rendered on that error, e.g. {404: "/404.html"}.
+	// The page is served with the error status code and
+	// it is compressed like any other file.
+	// If the page cannot be opened then just the status code is sent.
+	ErrorPages map[int]string
+	// OnError, if not nil, is fired on failures which are otherwise
+	// swallowed by the `FileServer`, e.g. on directory listing, push and compression errors.
+	// The "name" is the file or path the failure is related to
+	// and the "stage" describes the step of serving the request that failed.
+	// Use `errors.Is(err, fs.ErrNotExist)` and `errors.Is(err, fs.ErrPermission)`
+	// to separate missing files from permission and I/O errors.
+	OnError func(r *http.Request, name string, stage ErrorStage, err error)
+	// AccessLog, if not nil, is fired after each request is served.
+	// The entry holds the resolved file name, the status code, the bytes written
+	// (after compression) and more, see `AccessLogEntry`.
+	// Use `CommonLog`, `CombinedLog` or `JSONLog` to write
+	// the entries to an io.Writer, e.g. os.Stdout.
+	AccessLog AccessLogFunc
 }
 
 // Attachments options for files to be downloaded and saved locally by the client.
@@ -93,12 +285,21 @@ type Options struct {
 type Attachments struct {
 	// Set to true to enable the files to be downloaded and
 	// saved locally by the client, instead of serving the file.
+	// It applies to the files without a matching `Rules` entry.
 	Enable bool
-	// Options to send files with a limit of bytes sent per second.
+	// Rules decide per file pattern, media type or query parameter whether a file
+	// is downloaded or served inline, e.g. archives are downloaded, PDFs are served inline
+	// and any file is downloaded through a "?download=1" link.
+	// The first matching rule applies. See `AttachmentRule`.
+	Rules []AttachmentRule
+	// Options to send files with a limit of bytes sent per second,
+	// a zero Burst allows one second of the Limit.
 	Limit float64
 	Burst int
-	// Use this function to change the sent filename.
-	NameFunc func(systemName string) (attachmentName string)
+	// Use this function to change the sent filename, e.g. based on the user or a query parameter.
+	// Control characters and path separators of the result are removed
+	// and the name is sent as a quoted ASCII "filename" and a RFC 6266 "filename*" parameter.
+	NameFunc func(r *http.Request, systemName string) (attachmentName string)
 }
 
 // DirListFunc is the function signature for customizing directory and file listing.
@@ -127,7 +328,7 @@ func DirList(w http.ResponseWriter, r *http.Request, dirOptions Options, dirName
 		url := url.URL{Path: upath}
 
 		downloadAttr := ""
-		if dirOptions.Attachments.Enable && !d.IsDir() {
+		if _, download := dirOptions.Attachments.attachment(nil, path.Join(r.URL.Path, name)); download && !d.IsDir() {
 			downloadAttr = " download" // fixes chrome Resource interpreted, other browsers will just ignore this <a> attribute.
 		}
 
@@ -220,7 +421,8 @@ func DirListRich(options DirListRichOptions) DirListFunc {
 				viewName += "/"
 			}
 
-			shouldDownload := dirOptions.Attachments.Enable && !d.IsDir()
+			_, shouldDownload := dirOptions.Attachments.attachment(nil, path.Join(r.URL.Path, name))
+			shouldDownload = shouldDownload && !d.IsDir()
 			pageData.Files = append(pageData.Files, fileInfoData{
 				Info:     d,
 				ModTime:  d.ModTime().UTC().Format(http.TimeFormat),
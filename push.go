@@ -0,0 +1,242 @@
+package httpfs
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// pushTargets returns the URLs of the `PushTargets` and `PushTargetsRegexp`
+// of the served index file "name". The "requestPath" is the original request path,
+// it differs from the "name" on `SPA` fallbacks and clean URLs.
+// The "file" reports whether the "name" is the served file itself instead of its directory.
+func (s *fileServer) pushTargets(r *http.Request, name, requestPath string, file bool) []string {
+	if len(s.options.PushTargets) == 0 && len(s.options.PushTargetsRegexp) == 0 {
+		return nil
+	}
+
+	// the directory of the assets and its request URL, the file's directory
+	// on SPA fallbacks (e.g. "/admin/users/1" pushes the "/admin/" assets)
+	// and clean URLs (e.g. "/docs/about" pushes the "/docs/" assets).
+	pushDir := name
+	if file {
+		pushDir = path.Dir(name)
+	}
+	pushURL := requestPrefix(r, requestPath) + pushDir
+
+	var targets []string
+
+	if indexAssets, ok := s.options.PushTargets[r.URL.Path]; ok {
+		// Let's not try to use relative, give developer a clean control.
+		// rel := r.URL.Path
+		// if !info.IsDir() {
+		// 	rel = path.Dir(rel)
+		// }
+		// path.Join(rel, indexAsset)
+		for _, indexAsset := range indexAssets {
+			if indexAsset[0] != '/' {
+				// it's relative path.
+				indexAsset = path.Join(pushURL, indexAsset)
+			}
+
+			targets = append(targets, indexAsset)
+		}
+	}
+
+	if regex, ok := s.options.PushTargetsRegexp[r.URL.Path]; ok {
+		prefixURL := strings.TrimSuffix(pushURL, pushDir)
+		if prefixURL == "" {
+			prefixURL = "/"
+		}
+
+		var (
+			names []string
+			err   error
+		)
+
+		if s.options.PushTargetsLive {
+			names, err = s.matchNames(pushDir, regex)
+		} else {
+			set := s.pushSet()
+			names, err = set.names[r.URL.Path], set.errs[r.URL.Path]
+		}
+
+		if err != nil {
+			s.reportError(r, name, StagePush, err)
+			return targets
+		}
+
+		for _, indexAsset := range names {
+			targets = append(targets, path.Join(prefixURL, indexAsset))
+		}
+	}
+
+	return targets
+}
+
+// matchNames returns the file names under the "dir"
+// which match the "regex", hidden and index files are omitted.
+func (s *fileServer) matchNames(dir string, regex *regexp.Regexp) ([]string, error) {
+	names, err := findNames(s.fs, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+	for _, indexAsset := range s.options.filterHidden(names) {
+		// it's an index file, do not pushed that.
+		if s.isIndex("/" + indexAsset) {
+			continue
+		}
+
+		// match using relative path (without the first '/' slash)
+		// to keep consistency between the `PushTargets` behavior
+		if regex.MatchString(indexAsset) && !contains(matched, indexAsset) {
+			matched = append(matched, indexAsset)
+		}
+	}
+
+	return matched, nil
+}
+
+// pushSet holds the precomputed `PushTargetsRegexp` file names per request path
+// and the push target digests (see `PushOncePerVersion`).
+type pushSet struct {
+	version uint64 // the cache version these names were found on.
+	names   map[string][]string
+	errs    map[string]error // reported on the requests.
+	digests sync.Map         // map[file name]uint32, computed on first use.
+}
+
+// pushSet returns the precomputed `PushTargetsRegexp` file names,
+// it computes them again when a `Cache` file system was rebuilt.
+func (s *fileServer) pushSet() *pushSet {
+	var version uint64
+	if v, ok := s.fs.(versionedFS); ok {
+		version = v.cacheVersion()
+	}
+
+	if set := s.pushes.Load(); set != nil && set.version == version {
+		return set
+	}
+
+	s.pushMu.Lock()
+	defer s.pushMu.Unlock()
+
+	if set := s.pushes.Load(); set != nil && set.version == version {
+		return set
+	}
+
+	set := &pushSet{
+		version: version,
+		names:   make(map[string][]string, len(s.options.PushTargetsRegexp)),
+		errs:    make(map[string]error),
+	}
+
+	for requestPath, regex := range s.options.PushTargetsRegexp {
+		// the assets of a directory or the directory of an index file (e.g. on SPA)
+		// or of a clean URL page, e.g. "/about" of the "/about.html".
+		dir := requestPath
+		if info, err := stat(s.fs, dir); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) || !s.hasExtension(dir) {
+				set.errs[requestPath] = err
+				continue
+			}
+
+			dir = path.Dir(dir)
+		} else if !info.IsDir() {
+			dir = path.Dir(dir)
+		}
+
+		names, err := s.matchNames(dir, regex)
+		if err != nil {
+			set.errs[requestPath] = err
+			continue
+		}
+
+		set.names[requestPath] = names
+	}
+
+	s.pushes.Store(set)
+	return set
+}
+
+func stat(fileSystem http.FileSystem, name string) (os.FileInfo, error) {
+	f, err := fileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return f.Stat()
+}
+
+// writePreloadLinks adds a "Link: <target>; rel=preload; as=..." header per target
+// and sends them on a 103 Early Hints response if `Options.EarlyHints` is true.
+// Targets of unknown destination (see `preloadAs`) are skipped.
+func (s *fileServer) writePreloadLinks(w http.ResponseWriter, targets []string) {
+	h := w.Header()
+	added := false
+	for _, target := range targets {
+		as, crossOrigin := preloadAs(target)
+		if as == "" {
+			continue
+		}
+
+		link := "<" + target + ">; rel=preload; as=" + as
+		if crossOrigin {
+			link += "; crossorigin"
+		}
+
+		h.Add("Link", link)
+		added = true
+	}
+
+	if added && s.options.EarlyHints {
+		w.WriteHeader(http.StatusEarlyHints)
+	}
+}
+
+// preloadAs returns the "as" attribute of a preload link based on the file extension
+// and reports whether it should be fetched in CORS mode (e.g. fonts).
+func preloadAs(target string) (string, bool) {
+	if idx := strings.IndexByte(target, '?'); idx != -1 {
+		target = target[:idx]
+	}
+
+	switch strings.ToLower(path.Ext(target)) {
+	case ".js", ".mjs":
+		return "script", false
+	case ".css":
+		return "style", false
+	case ".woff", ".woff2", ".ttf", ".otf", ".eot":
+		return "font", true
+	case ".json":
+		return "fetch", true
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif", ".svg", ".ico", ".bmp":
+		return "image", false
+	case ".mp4", ".webm", ".ogv":
+		return "video", false
+	case ".mp3", ".wav", ".ogg", ".oga", ".m4a", ".flac":
+		return "audio", false
+	case ".vtt":
+		return "track", false
+	default:
+		return "", false
+	}
+}
+
+func contains(targets []string, target string) bool {
+	for _, t := range targets {
+		if t == target {
+			return true
+		}
+	}
+
+	return false
+}
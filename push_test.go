@@ -0,0 +1,187 @@
+package httpfs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+func TestPushTargets(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"index.html":          "index",
+		"favicon.ico":         "ico",
+		"docs/about.html":     "about",
+		"docs/js/main.js":     "js",
+		"admin/index.html":    "admin",
+		"admin/css/admin.css": "css",
+	})
+
+	opts := DefaultOptions
+	opts.Extensions = []string{".html"}
+	opts.SPA = true
+	opts.SPAIndexes = map[string]string{"/admin": "/admin/index.html"}
+	opts.PushTargets = map[string][]string{
+		"/":                 {"favicon.ico"},
+		"/docs/about":       {"js/main.js", "/favicon.ico"},
+		"/admin/index.html": {"css/admin.css"}, // the SPA index of "/admin/*".
+	}
+	h := FileServer(http.Dir(root), opts)
+
+	tests := []struct {
+		target string
+		pushed []string
+	}{
+		{"/", []string{"/favicon.ico"}},
+		{"/docs/about", []string{"/docs/js/main.js", "/favicon.ico"}},
+		{"/docs/%61bout", []string{"/docs/js/main.js", "/favicon.ico"}},
+		{"/admin/users/1", []string{"/admin/css/admin.css"}},
+		{"/admin/user%73/1", []string{"/admin/css/admin.css"}},
+		{"/?v=1", []string{"/favicon.ico"}},
+	}
+
+	for _, tt := range tests {
+		w := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status %d but got %d", tt.target, http.StatusOK, w.Code)
+		}
+
+		sort.Strings(w.pushed)
+		sort.Strings(tt.pushed)
+		if !reflect.DeepEqual(w.pushed, tt.pushed) {
+			t.Errorf("%s: expected pushed %v but got %v", tt.target, tt.pushed, w.pushed)
+		}
+	}
+}
+
+func TestPushTargetsRegexp(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"about.html":  "about",
+		"js/main.js":  "js",
+		"css/a.css":   "css",
+		"private.txt": "txt",
+	})
+
+	for _, live := range []bool{false, true} {
+		var reported []error
+		opts := DefaultOptions
+		opts.Extensions = []string{".html"}
+		opts.PushTargetsRegexp = map[string]*regexp.Regexp{"/about": MatchCommonAssets}
+		opts.PushTargetsLive = live
+		opts.Preload = true
+		opts.OnError = func(r *http.Request, name string, stage ErrorStage, err error) {
+			reported = append(reported, err)
+		}
+		h := FileServer(http.Dir(root), opts)
+
+		rec := serveTest(h, http.MethodGet, "/about")
+		links := rec.Header().Values("Link")
+		sort.Strings(links)
+
+		expected := []string{"</css/a.css>; rel=preload; as=style", "</js/main.js>; rel=preload; as=script"}
+		if !reflect.DeepEqual(links, expected) {
+			t.Errorf("live: %v: expected links %v but got %v", live, expected, links)
+		}
+
+		if len(reported) > 0 {
+			t.Errorf("live: %v: unexpected errors: %v", live, reported)
+		}
+	}
+}
+
+func TestPushTargetsAttachments(t *testing.T) {
+	root := testDir(t, map[string]string{"index.html": "index", "main.js": "js"})
+
+	tests := []struct {
+		attachments Attachments
+		pushed      int
+	}{
+		{Attachments{}, 1},
+		{Attachments{Enable: true}, 0},
+		{Attachments{Enable: true, Rules: []AttachmentRule{{MIMETypes: []string{"text/html"}, Inline: true}}}, 1},
+		{Attachments{Rules: []AttachmentRule{{Patterns: []string{"*.html"}}}}, 0},
+	}
+
+	for i, tt := range tests {
+		opts := DefaultOptions
+		opts.PushTargets = map[string][]string{"/": {"/main.js"}}
+		opts.Attachments = tt.attachments
+		h := FileServer(http.Dir(root), opts)
+
+		w := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if len(w.pushed) != tt.pushed {
+			t.Errorf("%d: expected %d pushed targets but got %v", i, tt.pushed, w.pushed)
+		}
+	}
+}
+
+// hintsRecorder records the Link headers of the 103 Early Hints responses.
+type hintsRecorder struct {
+	*httptest.ResponseRecorder
+	hints [][]string
+}
+
+func (w *hintsRecorder) WriteHeader(statusCode int) {
+	if statusCode == http.StatusEarlyHints {
+		w.hints = append(w.hints, append([]string(nil), w.Header().Values("Link")...))
+		return
+	}
+
+	w.ResponseRecorder.WriteHeader(statusCode)
+}
+
+func TestPreloadLinks(t *testing.T) {
+	root := testDir(t, map[string]string{"index.html": "index"})
+
+	expected := []string{
+		"</app.js>; rel=preload; as=script",
+		"</style.css?v=2>; rel=preload; as=style",
+		"</fonts/a.woff2>; rel=preload; as=font; crossorigin",
+		"</fonts/b.TTF>; rel=preload; as=font; crossorigin",
+		"</data.json>; rel=preload; as=fetch; crossorigin",
+		"</logo.png>; rel=preload; as=image",
+	}
+
+	for _, earlyHints := range []bool{false, true} {
+		var entry *AccessLogEntry
+		opts := DefaultOptions
+		opts.PushTargets = map[string][]string{
+			"/": {"/app.js", "/style.css?v=2", "/fonts/a.woff2", "/fonts/b.TTF", "/data.json", "/logo.png", "/readme.txt"},
+		}
+		opts.Preload = !earlyHints // implied.
+		opts.EarlyHints = earlyHints
+		opts.AccessLog = func(e *AccessLogEntry) { entry = e }
+		h := FileServer(http.Dir(root), opts)
+
+		w := &hintsRecorder{ResponseRecorder: httptest.NewRecorder()}
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "index" || entry.Status != http.StatusOK {
+			t.Fatalf("early hints: %v: expected the index file but got %d (logged %d): %q", earlyHints, w.Code, entry.Status, w.Body.String())
+		}
+
+		if links := w.Header().Values("Link"); !reflect.DeepEqual(links, expected) {
+			t.Errorf("early hints: %v: expected links %v but got %v", earlyHints, expected, links)
+		}
+
+		if !earlyHints && len(w.hints) > 0 {
+			t.Errorf("expected no 103 responses but got %v", w.hints)
+		} else if earlyHints && (len(w.hints) != 1 || !reflect.DeepEqual(w.hints[0], expected)) {
+			t.Errorf("expected one 103 response with the links %v but got %v", expected, w.hints)
+		}
+	}
+
+	// no 103 response without any link.
+	opts := DefaultOptions
+	opts.PushTargets = map[string][]string{"/": {"/readme.txt"}}
+	opts.EarlyHints = true
+	w := &hintsRecorder{ResponseRecorder: httptest.NewRecorder()}
+	FileServer(http.Dir(root), opts).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if len(w.hints) > 0 || w.Header().Get("Link") != "" {
+		t.Errorf("expected no links but got %v and %q", w.hints, w.Header().Get("Link"))
+	}
+}
@@ -0,0 +1,198 @@
+package httpfs
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidPath is returned by the hardened file systems
+// (see `SafeDir`, `PrefixDir` and `PrefixFS`) when a name contains
+// NUL bytes, backslashes, ".." segments or (double) encoded
+// dots and separators. `FileServer` responds with 400 Bad Request on it.
+var ErrInvalidPath = errors.New("invalid path")
+
+// validatePath reports whether the (already URL-decoded) "name"
+// is safe to be joined with a root directory.
+func validatePath(name string) error {
+	if strings.IndexByte(name, 0) != -1 || strings.IndexByte(name, '\\') != -1 {
+		return ErrInvalidPath
+	}
+
+	// The name is already decoded once,
+	// what is left is a double-encoding attempt.
+	lower := strings.ToLower(name)
+	for _, encoded := range [...]string{"%2e", "%2f", "%5c", "%00"} {
+		if strings.Contains(lower, encoded) {
+			return ErrInvalidPath
+		}
+	}
+
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return ErrInvalidPath
+		}
+	}
+
+	return nil
+}
+
+// joinPath joins the "prefix" with the "name"
+// making sure that the result stays under the "prefix".
+func joinPath(prefix, name string) (string, error) {
+	if err := validatePath(name); err != nil {
+		return "", err
+	}
+
+	return path.Join(prefix, path.Clean("/"+name)), nil
+}
+
+// SymlinkPolicy describes how a `SafeDir` file system
+// resolves symbolic links found inside its root directory.
+type SymlinkPolicy uint8
+
+const (
+	// SymlinksWithinRoot follows symbolic links
+	// only when their target resolves inside the root directory.
+	// This is the zero value.
+	SymlinksWithinRoot SymlinkPolicy = iota
+	// SymlinksFollow follows symbolic links anywhere,
+	// like the standard `http.Dir` does.
+	SymlinksFollow
+	// SymlinksRefuse never follows symbolic links.
+	SymlinksRefuse
+)
+
+// SafeDir returns a hardened alternative of the `http.Dir` file system
+// which serves the files under the "root" directory.
+// It rejects names with NUL bytes, backslashes, ".." segments and
+// double-encoded traversal sequences (see `ErrInvalidPath`)
+// and resolves symbolic links based on the given "symlinks" policy.
+// Symbolic links which are not allowed are omitted from directory listings
+// and opening them fails with `os.ErrPermission`.
+//
+// Usage:
+// fileSystem := SafeDir("./assets", SymlinksWithinRoot)
+// fileServer := FileServer(fileSystem, DefaultOptions)
+func SafeDir(root string, symlinks SymlinkPolicy) http.FileSystem {
+	if root == "" {
+		root = "."
+	}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		abs = filepath.Clean(root)
+	}
+
+	// Resolve the root itself, it may be a symbolic link too.
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		resolved = abs
+	}
+
+	return &safeDir{root: abs, resolvedRoot: resolved, symlinks: symlinks}
+}
+
+type safeDir struct {
+	root         string
+	resolvedRoot string
+	symlinks     SymlinkPolicy
+}
+
+var _ http.FileSystem = (*safeDir)(nil)
+
+func (d *safeDir) Open(name string) (http.File, error) {
+	if err := validatePath(name); err != nil {
+		return nil, err
+	}
+
+	name = path.Clean("/" + name)
+	fullname := filepath.Join(d.root, filepath.FromSlash(name))
+
+	if err := d.checkSymlinks(fullname); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(fullname)
+	if err != nil {
+		return nil, err
+	}
+
+	return &safeFile{File: f, dir: d, fullname: fullname}, nil
+}
+
+// checkSymlinks reports an error when the "fullname"
+// goes through a symbolic link which is not allowed by the policy.
+//
+// Note that the checks are not atomic with the open call,
+// the root directory should not be writable by untrusted parties.
+func (d *safeDir) checkSymlinks(fullname string) error {
+	switch d.symlinks {
+	case SymlinksFollow:
+		return nil
+	case SymlinksRefuse:
+		for p := fullname; p != d.root && len(p) > len(d.root); p = filepath.Dir(p) {
+			info, err := os.Lstat(p)
+			if err != nil {
+				return err
+			}
+
+			if info.Mode()&os.ModeSymlink != 0 {
+				return os.ErrPermission
+			}
+		}
+
+		return nil
+	default:
+		resolved, err := filepath.EvalSymlinks(fullname)
+		if err != nil {
+			return err
+		}
+
+		if !withinDir(d.resolvedRoot, resolved) {
+			return os.ErrPermission
+		}
+
+		return nil
+	}
+}
+
+// withinDir reports whether the "name" is the "root" or a child of it.
+func withinDir(root, name string) bool {
+	if name == root {
+		return true
+	}
+
+	return strings.HasPrefix(name, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// safeFile is the http.File of a `SafeDir`,
+// it omits the symbolic links that cannot be opened from `Readdir`.
+type safeFile struct {
+	*os.File
+	dir      *safeDir
+	fullname string
+}
+
+func (f *safeFile) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	if err != nil || f.dir.symlinks == SymlinksFollow {
+		return infos, err
+	}
+
+	allowed := infos[:0]
+	for _, info := range infos {
+		if info.Mode()&os.ModeSymlink != 0 {
+			if f.dir.checkSymlinks(filepath.Join(f.fullname, info.Name())) != nil {
+				continue
+			}
+		}
+
+		allowed = append(allowed, info)
+	}
+
+	return allowed, nil
+}
@@ -0,0 +1,211 @@
+package httpfs
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestJoinPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		err      error
+	}{
+		{"/a/b.txt", "/root/a/b.txt", nil},
+		{"a/b.txt", "/root/a/b.txt", nil},
+		{"/a/./b.txt", "/root/a/b.txt", nil},
+		{"/", "/root", nil},
+		{"/a..b/c", "/root/a..b/c", nil},
+		// NUL bytes.
+		{"/a\x00.txt", "", ErrInvalidPath},
+		// backslashes.
+		{"/a\\..\\secret", "", ErrInvalidPath},
+		{"\\secret", "", ErrInvalidPath},
+		// dot-dot segments.
+		{"/../secret", "", ErrInvalidPath},
+		{"/a/../../secret", "", ErrInvalidPath},
+		{"..", "", ErrInvalidPath},
+		// double encoded dots, slashes, backslashes and NUL bytes.
+		{"/%2e%2e/secret", "", ErrInvalidPath},
+		{"/%2E%2E/secret", "", ErrInvalidPath},
+		{"/..%2fsecret", "", ErrInvalidPath},
+		{"/..%2Fsecret", "", ErrInvalidPath},
+		{"/..%5csecret", "", ErrInvalidPath},
+		{"/a%00.txt", "", ErrInvalidPath},
+	}
+
+	for _, tt := range tests {
+		got, err := joinPath("/root", tt.name)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%q: expected error %v but got %v", tt.name, tt.err, err)
+			continue
+		}
+
+		if got != tt.expected {
+			t.Errorf("%q: expected %q but got %q", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestPrefixDir(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"secret.txt":   "secret",
+		"assets/a.txt": "a",
+	})
+
+	fileSystem := PrefixDir("/assets", http.Dir(root))
+	if _, err := fileSystem.Open("/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"/../secret.txt", "/%2e%2e/secret.txt", "\\..\\secret.txt"} {
+		if _, err := fileSystem.Open(name); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%q: expected %v but got %v", name, ErrInvalidPath, err)
+		}
+	}
+
+	h := FileServer(fileSystem, DefaultOptions)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.URL.Path = "/%2e%2e/secret.txt" // decoded once from "/%252e%252e/secret.txt".
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d but got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestSafeDirInvalidPaths(t *testing.T) {
+	root := testDir(t, map[string]string{"a.txt": "a"})
+	fileSystem := SafeDir(root, SymlinksWithinRoot)
+
+	if _, err := fileSystem.Open("/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"/a.txt\x00", "/..\\a.txt", "/../a.txt", "/%2e%2e/a.txt", "/..%2fa.txt", "/..%5ca.txt"} {
+		if _, err := fileSystem.Open(name); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%q: expected %v but got %v", name, ErrInvalidPath, err)
+		}
+	}
+}
+
+func TestSafeDirSymlinks(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+
+	for _, dir := range []string{filepath.Join(root, "dir"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, contents := range map[string]string{
+		filepath.Join(root, "dir", "a.txt"):  "a",
+		filepath.Join(outside, "secret.txt"): "secret",
+	} {
+		if err := os.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		filepath.Join(root, "within.txt"): filepath.Join(root, "dir", "a.txt"),
+		filepath.Join(root, "withindir"):  filepath.Join(root, "dir"),
+		filepath.Join(root, "escape.txt"): filepath.Join(outside, "secret.txt"),
+		filepath.Join(root, "escapedir"):  outside,
+		filepath.Join(root, "relative"):   filepath.Join("..", "outside", "secret.txt"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symbolic links are not supported: %v", err)
+		}
+	}
+
+	// the root itself may be a symbolic link.
+	rootLink := filepath.Join(base, "rootlink")
+	if err := os.Symlink(root, rootLink); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+
+	tests := []struct {
+		policy  SymlinkPolicy
+		allowed []string
+		denied  []string
+		listed  []string
+	}{
+		{
+			policy:  SymlinksWithinRoot,
+			allowed: []string{"/dir/a.txt", "/within.txt", "/withindir/a.txt"},
+			denied:  []string{"/escape.txt", "/escapedir/secret.txt", "/relative"},
+			listed:  []string{"dir", "within.txt", "withindir"},
+		},
+		{
+			policy:  SymlinksFollow,
+			allowed: []string{"/dir/a.txt", "/within.txt", "/withindir/a.txt", "/escape.txt", "/escapedir/secret.txt", "/relative"},
+			listed:  []string{"dir", "escape.txt", "escapedir", "relative", "within.txt", "withindir"},
+		},
+		{
+			policy:  SymlinksRefuse,
+			allowed: []string{"/dir/a.txt"},
+			denied:  []string{"/within.txt", "/withindir/a.txt", "/escape.txt", "/escapedir/secret.txt", "/relative"},
+			listed:  []string{"dir"},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, dir := range []string{root, rootLink} {
+			fileSystem := SafeDir(dir, tt.policy)
+
+			for _, name := range tt.allowed {
+				f, err := fileSystem.Open(name)
+				if err != nil {
+					t.Errorf("%d: %s: expected %q to be opened but got %v", tt.policy, dir, name, err)
+					continue
+				}
+				f.Close()
+			}
+
+			for _, name := range tt.denied {
+				if _, err := fileSystem.Open(name); !errors.Is(err, fs.ErrPermission) {
+					t.Errorf("%d: %s: expected %q to be denied but got %v", tt.policy, dir, name, err)
+				}
+			}
+
+			f, err := fileSystem.Open("/")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			infos, err := f.Readdir(-1)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var listed []string
+			for _, info := range infos {
+				listed = append(listed, info.Name())
+			}
+			sort.Strings(listed)
+
+			if len(listed) != len(tt.listed) {
+				t.Errorf("%d: %s: expected listed %v but got %v", tt.policy, dir, tt.listed, listed)
+				continue
+			}
+
+			for i := range listed {
+				if listed[i] != tt.listed[i] {
+					t.Errorf("%d: %s: expected listed %v but got %v", tt.policy, dir, tt.listed, listed)
+					break
+				}
+			}
+		}
+	}
+}
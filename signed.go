@@ -0,0 +1,173 @@
+package httpfs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The query parameters of a signed URL, see `URLSigner`.
+const (
+	signExpiresParam  = "expires"
+	signKeyIDParam    = "kid"
+	signIPParam       = "ip"
+	signDownloadParam = "dl"
+	signatureParam    = "sig"
+)
+
+// URLSigner generates and verifies HMAC-signed, expiring URLs,
+// e.g. "/files/report.pdf?expires=1700000000&sig=...",
+// to hand out time-limited links to private files without a session.
+// See `Options.SignedURLs`.
+type URLSigner struct {
+	// Keys maps a key id to its HMAC-SHA256 secret key.
+	// All of them verify the signed URLs, so a key can be rotated
+	// without invalidating the links signed with the previous one.
+	Keys map[string][]byte
+	// KeyID is the id of the `Keys` entry which signs new URLs.
+	KeyID string
+	// Patterns holds the glob patterns (see `path.Match`) of the files
+	// that require a signed URL, matched like the `Options.Deny` ones,
+	// e.g. "/private/*". Empty requires a signed URL for all files.
+	// They are matched against the request path and the file actually served,
+	// e.g. the "/docs/index.html" of "/docs/" or the "/report.pdf" of a clean URL.
+	Patterns []string
+	// ClientIP returns the IP address of the client a URL is bound to,
+	// see `SignOptions.ClientIP`. Defaults to the host of the request's RemoteAddr.
+	ClientIP func(r *http.Request) string
+}
+
+// SignOptions holds the optional settings of a signed URL. See `URLSigner.Sign`.
+type SignOptions struct {
+	// ClientIP, if not empty, binds the URL to a client IP address.
+	ClientIP string
+	// Download, if true, forces the file to be downloaded
+	// as an attachment (see `Options.Attachments`).
+	Download bool
+}
+
+// Sign returns the "urlPath" signed to be valid until "expires",
+// e.g. "/files/report.pdf?expires=1700000000&sig=...".
+// The "urlPath" is the full path the clients request,
+// including any prefix the `FileServer` is registered with.
+// It panics if the `KeyID` has no key.
+func (s *URLSigner) Sign(urlPath string, expires time.Time, opts SignOptions) string {
+	key, ok := s.Keys[s.KeyID]
+	if !ok || len(key) == 0 {
+		panic("URLSigner: missing key of KeyID " + strconv.Quote(s.KeyID))
+	}
+
+	urlPath = (&url.URL{Path: urlPath}).EscapedPath()
+	exp := strconv.FormatInt(expires.Unix(), 10)
+
+	query := url.Values{}
+	query.Set(signExpiresParam, exp)
+	if s.KeyID != "" {
+		query.Set(signKeyIDParam, s.KeyID)
+	}
+	if opts.ClientIP != "" {
+		query.Set(signIPParam, "1")
+	}
+	if opts.Download {
+		query.Set(signDownloadParam, "1")
+	}
+
+	query.Set(signatureParam, signature(key, urlPath, exp, s.KeyID, opts.ClientIP, opts.Download))
+	return urlPath + "?" + query.Encode()
+}
+
+// verify reports whether the request URL was signed by one of the `Keys`
+// and it is not expired. It also reports whether it should be downloaded.
+func (s *URLSigner) verify(r *http.Request) (valid bool, download bool) {
+	query := r.URL.Query()
+
+	sig := query.Get(signatureParam)
+	exp := query.Get(signExpiresParam)
+	if sig == "" || exp == "" {
+		return false, false
+	}
+
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false, false
+	}
+
+	keyID := query.Get(signKeyIDParam)
+	key, ok := s.Keys[keyID]
+	if !ok || len(key) == 0 {
+		return false, false
+	}
+
+	var clientIP string
+	if query.Get(signIPParam) == "1" {
+		if s.ClientIP != nil {
+			clientIP = s.ClientIP(r)
+		} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			clientIP = host
+		} else {
+			clientIP = r.RemoteAddr
+		}
+
+		if clientIP == "" {
+			return false, false
+		}
+	}
+
+	download = query.Get(signDownloadParam) == "1"
+
+	// the request path as the client sent it, before any http.StripPrefix.
+	urlPath := r.RequestURI
+	if idx := strings.IndexByte(urlPath, '?'); idx != -1 {
+		urlPath = urlPath[:idx]
+	}
+
+	expected := signature(key, urlPath, exp, keyID, clientIP, download)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return false, false
+	}
+
+	return true, download
+}
+
+// requiresSignature reports whether the "name" matches the `Patterns`.
+func (s *URLSigner) requiresSignature(name string) bool {
+	return matchAny(s.Patterns, name)
+}
+
+// signatureCheck verifies the signed URL of a request, at most once,
+// for the request path and the file actually served, e.g. an index file.
+type signatureCheck struct {
+	signer *URLSigner
+	r      *http.Request
+
+	done     bool
+	valid    bool
+	download bool // the signed URL forces the file to be downloaded.
+}
+
+// allowed reports whether the "name" does not require a signature
+// or the request URL is validly signed.
+func (c *signatureCheck) allowed(name string) bool {
+	if c.signer == nil || !c.signer.requiresSignature(name) {
+		return true
+	}
+
+	if !c.done {
+		c.done = true
+		c.valid, c.download = c.signer.verify(c.r)
+	}
+
+	return c.valid
+}
+
+func signature(key []byte, urlPath, expires, keyID, clientIP string, download bool) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(urlPath + "\n" + expires + "\n" + keyID + "\n" + clientIP + "\n" + strconv.FormatBool(download)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
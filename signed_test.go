@@ -0,0 +1,71 @@
+package httpfs
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedURLs(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"public.txt":              "public",
+		"private/notes.txt":       "notes",
+		"private/report.pdf":      "report",
+		"private/docs/index.html": "docs",
+	})
+
+	signer := &URLSigner{
+		Keys:     map[string][]byte{"old": []byte("old secret"), "new": []byte("new secret")},
+		KeyID:    "new",
+		Patterns: []string{"*.pdf", "/private/*.txt", "/private/docs/*"},
+	}
+
+	opts := DefaultOptions
+	opts.SignedURLs = signer
+	opts.Extensions = []string{".pdf"}
+	h := FileServer(http.Dir(root), opts)
+
+	valid := signer.Sign("/private/report.pdf", time.Now().Add(time.Hour), SignOptions{})
+	expired := signer.Sign("/private/report.pdf", time.Now().Add(-time.Hour), SignOptions{})
+	download := signer.Sign("/private/report.pdf", time.Now().Add(time.Hour), SignOptions{Download: true})
+	boundIP := signer.Sign("/private/report.pdf", time.Now().Add(time.Hour), SignOptions{ClientIP: "10.0.0.1"})
+
+	oldSigner := *signer
+	oldSigner.KeyID = "old"
+	rotated := oldSigner.Sign("/private/report.pdf", time.Now().Add(time.Hour), SignOptions{})
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/public.txt", http.StatusOK},
+		{"/private/report.pdf", http.StatusForbidden},
+		{"/private//notes.txt", http.StatusForbidden},
+		{"/x/../private/notes.txt", http.StatusForbidden},
+		{"/x/../private/docs/index.html", http.StatusForbidden},
+		{valid, http.StatusOK},
+		{rotated, http.StatusOK},
+		{expired, http.StatusForbidden},
+		{boundIP, http.StatusForbidden}, // httptest requests come from 192.0.2.1.
+		{strings.Replace(valid, "report.pdf", "other.pdf", 1), http.StatusForbidden},
+		{strings.Replace(valid, "kid=new", "kid=old", 1), http.StatusForbidden},
+		{valid + "&" + url.Values{"dl": {"1"}}.Encode(), http.StatusForbidden},
+		// the served file requires a signature, not the request path.
+		{"/private/report", http.StatusForbidden},
+		{"/private/docs", http.StatusForbidden},
+		{signer.Sign("/private/report", time.Now().Add(time.Hour), SignOptions{}), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		if rec := serveTest(h, http.MethodGet, tt.target); rec.Code != tt.status {
+			t.Errorf("%s: expected status %d but got %d", tt.target, tt.status, rec.Code)
+		}
+	}
+
+	rec := serveTest(h, http.MethodGet, download)
+	if got := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment;") {
+		t.Fatalf("expected an attachment but got %q", got)
+	}
+}
@@ -0,0 +1,154 @@
+package httpfs
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// spaFallback reports whether a missing "name" should be served
+// with the SPA index file, see `Options.SPA`.
+func (s *fileServer) spaFallback(r *http.Request, name string) bool {
+	if !s.options.SPA {
+		return false
+	}
+
+	if index := s.spaIndex(name); index != "" {
+		if name == index {
+			return false // the app's index file itself is missing.
+		}
+	} else if path.Dir(name) == "/" && s.isIndex(name) {
+		return false // the index file itself is missing.
+	}
+
+	for _, p := range s.options.SPAExclude {
+		if hasPathPrefix(name, p) {
+			return false
+		}
+	}
+
+	if s.options.SPAHTMLOnly {
+		if path.Ext(name) != "" || !acceptsHTML(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// spaIndex returns the index file of the longest `Options.SPAIndexes` prefix
+// the "name" is under, or an empty string for the root app.
+func (s *fileServer) spaIndex(name string) string {
+	var longest, index string
+	for p, idx := range s.options.SPAIndexes {
+		if hasPathPrefix(name, p) && (index == "" || len(p) > len(longest)) {
+			longest, index = p, idx
+		}
+	}
+
+	return index
+}
+
+// openSPAIndex opens the SPA index file that a missing "name" falls back to,
+// the one of its `Options.SPAIndexes` app or the root index file.
+func (s *fileServer) openSPAIndex(r *http.Request, name string) (http.File, string, error) {
+	index := s.spaIndex(name)
+	if index == "" {
+		// try find the main index, in priority order.
+		f, _, index, err := s.openIndex(r, "/", StageOpen)
+		return f, index, err
+	}
+
+	if s.options.hiddenStatus(index) != 0 {
+		return nil, "", fs.ErrNotExist
+	}
+
+	f, err := s.open(index, r)
+	if err != nil {
+		s.reportError(r, index, StageOpen, err)
+		return nil, "", err
+	}
+
+	return f, index, nil
+}
+
+// hasPathPrefix reports whether the "name" is the "prefix" path
+// or it is under it, e.g. "/api" and "/api/users" are under "/api"
+// but "/apis" is not.
+func hasPathPrefix(name, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+
+	return name == prefix || strings.HasPrefix(name, prefix+"/")
+}
+
+// acceptsHTML reports whether the client accepts a HTML response,
+// e.g. a browser navigation, in contrast to a fetch of an API or a script.
+func acceptsHTML(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			params := strings.Split(mediaRange, ";")
+			mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+			if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+				continue
+			}
+
+			acceptable := true
+			for _, param := range params[1:] {
+				if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+					// explicitly not acceptable, e.g. "text/html;q=0".
+					acceptable = strings.Trim(q[2:], "0.") != ""
+				}
+			}
+
+			if acceptable {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// statusWriter writes the "status" instead of the 200 OK one,
+// e.g. to serve the SPA index file with a 404 status, see `Options.SPAStatus`.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+var _ http.Flusher = (*statusWriter)(nil)
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if statusCode >= http.StatusOK {
+		w.wroteHeader = true
+	}
+	if statusCode == http.StatusOK {
+		statusCode = w.status
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the original response writer, see `http.ResponseController`.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
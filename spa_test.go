@@ -0,0 +1,107 @@
+package httpfs
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSPA(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"index.html": "app",
+		"main.js":    "js",
+	})
+
+	opts := DefaultOptions
+	opts.SPA = true
+	opts.SPAHTMLOnly = true
+	opts.SPAExclude = []string{"/api", "/static/"}
+	opts.SPAStatus = http.StatusNotFound
+	h := FileServer(http.Dir(root), opts)
+
+	const html = "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8"
+	tests := []struct {
+		target string
+		accept string
+		status int
+		body   string
+	}{
+		{"/users/1", html, http.StatusNotFound, "app"},
+		{"/users/1", "application/xhtml+xml", http.StatusNotFound, "app"},
+		{"/users/1", "TEXT/HTML; q=0.5", http.StatusNotFound, "app"},
+		{"/users/1", "text/html;q=0, */*", http.StatusNotFound, ""},
+		{"/users/1", "text/html; q=0.000", http.StatusNotFound, ""},
+		{"/users/1", "application/json", http.StatusNotFound, ""},
+		{"/users/1", "", http.StatusNotFound, ""},
+		{"/missing.js", html, http.StatusNotFound, ""},
+		{"/api", html, http.StatusNotFound, ""},
+		{"/api/users", html, http.StatusNotFound, ""},
+		{"/apis", html, http.StatusNotFound, "app"},
+		{"/static/a", html, http.StatusNotFound, ""},
+		{"/main.js", "", http.StatusOK, "js"},
+		{"/", html, http.StatusOK, "app"},
+	}
+
+	for _, tt := range tests {
+		rec := serveTest(h, http.MethodGet, tt.target, "Accept", tt.accept)
+		if rec.Code != tt.status || rec.Body.String() != tt.body {
+			t.Errorf("%s (%s): expected %d: %q but got %d: %q", tt.target, tt.accept, tt.status, tt.body, rec.Code, rec.Body.String())
+		}
+	}
+
+	// any request falls back without SPAHTMLOnly, with the default 200 OK status.
+	opts.SPAHTMLOnly = false
+	opts.SPAStatus = 0
+	h = FileServer(http.Dir(root), opts)
+
+	for _, target := range []string{"/users/1", "/missing.js"} {
+		rec := serveTest(h, http.MethodGet, target, "Accept", "application/json")
+		if rec.Code != http.StatusOK || rec.Body.String() != "app" {
+			t.Errorf("%s: expected the SPA index but got %d: %q", target, rec.Code, rec.Body.String())
+		}
+	}
+
+	if rec := serveTest(h, http.MethodGet, "/api/users"); rec.Code != http.StatusNotFound {
+		t.Errorf("/api/users: expected the excluded prefix to respond with 404 but got %d", rec.Code)
+	}
+}
+
+func TestSPAIndexes(t *testing.T) {
+	root := testDir(t, map[string]string{
+		"index.html":            "root",
+		"admin/index.html":      "admin",
+		"admin/users/app.html":  "users",
+		"shop/readme.txt":       "readme",
+		"admins/placeholder.md": "placeholder",
+	})
+
+	opts := DefaultOptions
+	opts.SPA = true
+	opts.SPAIndexes = map[string]string{
+		"/admin":        "/admin/index.html",
+		"/admin/users/": "/admin/users/app.html",
+		"/shop":         "/shop/index.html",
+	}
+	h := FileServer(http.Dir(root), opts)
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/missing", http.StatusOK, "root"},
+		{"/admins/1", http.StatusOK, "root"},
+		{"/admin/settings", http.StatusOK, "admin"},
+		{"/admin/users/settings", http.StatusOK, "users"},
+		{"/admin/users/1", http.StatusOK, "users"},
+		// the app's own index file is missing.
+		{"/shop/cart", http.StatusNotFound, ""},
+		{"/shop/index.html", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		rec := serveTest(h, http.MethodGet, tt.target)
+		if rec.Code != tt.status || rec.Body.String() != tt.body {
+			t.Errorf("%s: expected %d: %q but got %d: %q", tt.target, tt.status, tt.body, rec.Code, rec.Body.String())
+		}
+	}
+}